@@ -0,0 +1,5 @@
+BEGIN;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
+COMMIT;
@@ -0,0 +1,49 @@
+BEGIN;
+CREATE TABLE roles (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(64) UNIQUE NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT now(),
+    updated_at TIMESTAMP DEFAULT now()
+);
+
+CREATE TABLE permissions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(64) UNIQUE NOT NULL,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE role_permissions (
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id INTEGER NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('admin', 'Full access to every resource'),
+    ('user', 'Default role for registered users');
+
+INSERT INTO permissions (name, description) VALUES
+    ('tasks:read', 'View tasks'),
+    ('tasks:write', 'Create and update tasks'),
+    ('tasks:delete', 'Delete tasks'),
+    ('projects:read', 'View projects'),
+    ('projects:write', 'Create and update projects'),
+    ('projects:delete', 'Delete projects'),
+    ('users:read', 'View other users'),
+    ('users:write', 'Update other users'),
+    ('users:delete', 'Delete other users'),
+    ('roles:manage', 'Create roles and assign them to users');
+
+INSERT INTO role_permissions (role_id, permission_id)
+    SELECT r.id, p.id FROM roles r CROSS JOIN permissions p WHERE r.name = 'admin';
+
+INSERT INTO role_permissions (role_id, permission_id)
+    SELECT r.id, p.id FROM roles r JOIN permissions p
+    ON p.name IN ('tasks:read', 'tasks:write', 'tasks:delete', 'projects:read', 'projects:write', 'projects:delete')
+    WHERE r.name = 'user';
+
+-- The previous default resolved to the SQL `user` keyword (the connecting DB role)
+ALTER TABLE users ALTER COLUMN role SET DEFAULT 'user';
+UPDATE users SET role = 'user' WHERE role IS NULL OR role NOT IN (SELECT name FROM roles);
+COMMIT;
@@ -0,0 +1,13 @@
+CREATE TABLE resource_grants (
+    id SERIAL PRIMARY KEY,
+    resource_type VARCHAR(32) NOT NULL,
+    resource_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    access VARCHAR(16) NOT NULL,
+    granted_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT now(),
+    updated_at TIMESTAMP DEFAULT now(),
+    UNIQUE (resource_type, resource_id, user_id)
+);
+
+CREATE INDEX idx_resource_grants_user ON resource_grants (user_id, resource_type);
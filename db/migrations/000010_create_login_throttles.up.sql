@@ -0,0 +1,9 @@
+CREATE TABLE login_throttles (
+    id SERIAL PRIMARY KEY,
+    scope VARCHAR(16) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL DEFAULT now(),
+    locked_until TIMESTAMP,
+    UNIQUE (scope, key)
+);
@@ -0,0 +1 @@
+ALTER TABLE one_time_tokens DROP COLUMN binding_hash;
@@ -0,0 +1 @@
+ALTER TABLE one_time_tokens ADD COLUMN binding_hash CHAR(64);
@@ -0,0 +1,4 @@
+BEGIN;
+DROP TABLE IF EXISTS webauthn_sessions;
+DROP TABLE IF EXISTS webauthn_credentials;
+COMMIT;
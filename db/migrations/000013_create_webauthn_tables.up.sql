@@ -0,0 +1,29 @@
+BEGIN;
+CREATE TABLE webauthn_credentials (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    credential_id BYTEA UNIQUE NOT NULL,
+    public_key BYTEA NOT NULL,
+    attestation_type VARCHAR(64) NOT NULL DEFAULT '',
+    transports TEXT NOT NULL DEFAULT '',
+    flags SMALLINT NOT NULL DEFAULT 0,
+    aaguid BYTEA,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    clone_warning BOOLEAN NOT NULL DEFAULT FALSE,
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT now()
+);
+
+CREATE INDEX idx_webauthn_credentials_user ON webauthn_credentials (user_id);
+
+CREATE TABLE webauthn_sessions (
+    id SERIAL PRIMARY KEY,
+    session_key_hash CHAR(64) UNIQUE NOT NULL,
+    purpose VARCHAR(32) NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    data TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT now()
+);
+COMMIT;
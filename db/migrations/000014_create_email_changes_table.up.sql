@@ -0,0 +1,17 @@
+CREATE TABLE email_changes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    old_email VARCHAR(255) NOT NULL,
+    new_email VARCHAR(255) NOT NULL,
+    old_verified BOOLEAN NOT NULL DEFAULT false,
+    confirm_token_hash CHAR(64) UNIQUE NOT NULL,
+    revert_token_hash CHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revert_expires_at TIMESTAMP NOT NULL,
+    confirmed_at TIMESTAMP,
+    reverted_at TIMESTAMP,
+    cancelled_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT now()
+);
+
+CREATE INDEX idx_email_changes_user_id ON email_changes (user_id);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS user_avatars;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS display_name,
+    DROP COLUMN IF EXISTS timezone,
+    DROP COLUMN IF EXISTS locale,
+    DROP COLUMN IF EXISTS avatar_updated_at;
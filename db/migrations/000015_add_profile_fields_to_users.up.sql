@@ -0,0 +1,12 @@
+ALTER TABLE users
+    ADD COLUMN display_name VARCHAR(100) NOT NULL DEFAULT '',
+    ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    ADD COLUMN locale VARCHAR(16) NOT NULL DEFAULT 'en',
+    ADD COLUMN avatar_updated_at TIMESTAMP;
+
+CREATE TABLE user_avatars (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    content_type VARCHAR(32) NOT NULL,
+    data BYTEA NOT NULL,
+    updated_at TIMESTAMP DEFAULT now()
+);
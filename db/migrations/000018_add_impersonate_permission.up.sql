@@ -0,0 +1,8 @@
+BEGIN;
+INSERT INTO permissions (name, description) VALUES
+    ('users:impersonate', 'Act as another user for support');
+
+INSERT INTO role_permissions (role_id, permission_id)
+    SELECT r.id, p.id FROM roles r JOIN permissions p ON p.name = 'users:impersonate'
+    WHERE r.name = 'admin';
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+ALTER TABLE users
+    ADD COLUMN deletion_scheduled_at TIMESTAMP,
+    ADD COLUMN disabled_at TIMESTAMP;
+
+UPDATE users SET deletion_scheduled_at = status_until WHERE status = 'pending_deletion';
+UPDATE users SET disabled_at = now() WHERE status IN ('suspended', 'locked');
+
+DROP INDEX IF EXISTS idx_users_status;
+ALTER TABLE users
+    DROP COLUMN status,
+    DROP COLUMN status_reason,
+    DROP COLUMN status_until;
+
+CREATE INDEX idx_users_deletion_scheduled_at ON users (deletion_scheduled_at) WHERE deletion_scheduled_at IS NOT NULL;
+COMMIT;
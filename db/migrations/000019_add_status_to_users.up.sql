@@ -0,0 +1,18 @@
+BEGIN;
+ALTER TABLE users
+    ADD COLUMN status VARCHAR(32) NOT NULL DEFAULT 'active',
+    ADD COLUMN status_reason TEXT NOT NULL DEFAULT '',
+    ADD COLUMN status_until TIMESTAMP;
+
+UPDATE users SET status = 'pending_deletion', status_until = deletion_scheduled_at
+    WHERE deletion_scheduled_at IS NOT NULL;
+UPDATE users SET status = 'suspended', status_until = NULL
+    WHERE disabled_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_users_deletion_scheduled_at;
+ALTER TABLE users
+    DROP COLUMN deletion_scheduled_at,
+    DROP COLUMN disabled_at;
+
+CREATE INDEX idx_users_status ON users (status) WHERE status <> 'active';
+COMMIT;
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS digest_deliveries;
+
+DROP INDEX IF EXISTS idx_users_digest_frequency;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS digest_weekday,
+    DROP COLUMN IF EXISTS digest_hour,
+    DROP COLUMN IF EXISTS digest_frequency;
@@ -0,0 +1,16 @@
+ALTER TABLE users
+    ADD COLUMN digest_frequency VARCHAR(16) NOT NULL DEFAULT 'off',
+    ADD COLUMN digest_hour SMALLINT NOT NULL DEFAULT 8,
+    ADD COLUMN digest_weekday SMALLINT NOT NULL DEFAULT 1;
+
+CREATE INDEX idx_users_digest_frequency ON users (digest_frequency) WHERE digest_frequency <> 'off';
+
+-- One row per user and local calendar day a digest was handled; the primary key makes
+-- sending idempotent across restarts and replicas
+CREATE TABLE digest_deliveries (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    digest_date DATE NOT NULL,
+    item_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT now(),
+    PRIMARY KEY (user_id, digest_date)
+);
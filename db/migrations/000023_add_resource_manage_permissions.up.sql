@@ -0,0 +1,9 @@
+BEGIN;
+INSERT INTO permissions (name, description) VALUES
+    ('tasks:manage', 'Access every task regardless of sharing'),
+    ('projects:manage', 'Access every project regardless of sharing');
+
+INSERT INTO role_permissions (role_id, permission_id)
+    SELECT r.id, p.id FROM roles r JOIN permissions p ON p.name IN ('tasks:manage', 'projects:manage')
+    WHERE r.name = 'admin';
+COMMIT;
@@ -0,0 +1 @@
+DELETE FROM permissions WHERE name IN ('profile:read', 'profile:write', 'notifications:read', 'notifications:write');
@@ -0,0 +1,12 @@
+BEGIN;
+INSERT INTO permissions (name, description) VALUES
+    ('profile:read', 'View your own profile and avatar'),
+    ('profile:write', 'Update your own profile and avatar'),
+    ('notifications:read', 'View your notifications and notification settings'),
+    ('notifications:write', 'Mark notifications read and change notification settings');
+
+-- Every existing role already reached these routes without a permission
+INSERT INTO role_permissions (role_id, permission_id)
+    SELECT r.id, p.id FROM roles r CROSS JOIN permissions p
+    WHERE p.name IN ('profile:read', 'profile:write', 'notifications:read', 'notifications:write');
+COMMIT;
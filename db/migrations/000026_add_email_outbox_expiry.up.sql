@@ -0,0 +1 @@
+ALTER TABLE email_outbox ADD COLUMN expires_at TIMESTAMP;
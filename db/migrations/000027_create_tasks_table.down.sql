@@ -0,0 +1,2 @@
+DELETE FROM resource_grants WHERE resource_type = 'task';
+DROP TABLE IF EXISTS tasks;
@@ -0,0 +1,11 @@
+CREATE TABLE tasks (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    status BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP DEFAULT now(),
+    updated_at TIMESTAMP DEFAULT now()
+);
+
+-- Tasks used to live in memory and were renumbered from 1 on every restart; grants on those IDs
+-- would otherwise give their holders access to the unrelated tasks now created with the same IDs
+DELETE FROM resource_grants WHERE resource_type = 'task';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_tasks_open_due;
+ALTER TABLE tasks DROP COLUMN IF EXISTS due_at;
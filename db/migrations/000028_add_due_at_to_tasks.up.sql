@@ -0,0 +1,3 @@
+ALTER TABLE tasks ADD COLUMN due_at TIMESTAMP;
+
+CREATE INDEX idx_tasks_open_due ON tasks (due_at) WHERE status = false AND due_at IS NOT NULL;
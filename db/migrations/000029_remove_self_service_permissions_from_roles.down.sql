@@ -0,0 +1,4 @@
+INSERT INTO role_permissions (role_id, permission_id)
+    SELECT r.id, p.id FROM roles r CROSS JOIN permissions p
+    WHERE p.name IN ('profile:read', 'profile:write', 'notifications:read', 'notifications:write')
+ON CONFLICT DO NOTHING;
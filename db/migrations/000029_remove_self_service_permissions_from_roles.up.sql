@@ -0,0 +1,4 @@
+-- Every account reaches its own profile and notifications; these permissions only scope access tokens
+DELETE FROM role_permissions WHERE permission_id IN (
+    SELECT id FROM permissions WHERE name IN ('profile:read', 'profile:write', 'notifications:read', 'notifications:write')
+);
@@ -0,0 +1,2 @@
+-- Users are looked up by email without regard to case
+CREATE INDEX IF NOT EXISTS idx_users_lower_email ON users (LOWER(email));
@@ -0,0 +1,41 @@
+package config
+
+import "time"
+
+// AccountSettings controls the account lifecycle
+type AccountSettings struct {
+	// DeletionGracePeriod is how long a self-requested deletion can still be cancelled by logging in
+	DeletionGracePeriod time.Duration
+	// PurgeInterval is how often accounts past their grace period are purged
+	PurgeInterval time.Duration
+	// ReauthWindow is how long after logging in a session counts as freshly authenticated
+	ReauthWindow time.Duration
+}
+
+// Account is the active account lifecycle configuration
+var Account = AccountSettings{
+	DeletionGracePeriod: 14 * 24 * time.Hour,
+	PurgeInterval:       time.Hour,
+	ReauthWindow:        10 * time.Minute,
+}
+
+// loadAccountSettings reads the ACCOUNT_* environment variables
+func loadAccountSettings() AccountSettings {
+	graceDays := getEnvInt("ACCOUNT_DELETION_GRACE_DAYS", 14)
+	if graceDays < 0 {
+		graceDays = 14
+	}
+	purgeMinutes := getEnvInt("ACCOUNT_PURGE_INTERVAL_MINUTES", 60)
+	if purgeMinutes < 1 {
+		purgeMinutes = 60
+	}
+	reauthMinutes := getEnvInt("ACCOUNT_REAUTH_WINDOW_MINUTES", 10)
+	if reauthMinutes < 1 {
+		reauthMinutes = 10
+	}
+	return AccountSettings{
+		DeletionGracePeriod: time.Duration(graceDays) * 24 * time.Hour,
+		PurgeInterval:       time.Duration(purgeMinutes) * time.Minute,
+		ReauthWindow:        time.Duration(reauthMinutes) * time.Minute,
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/joho/godotenv"
@@ -36,10 +37,41 @@ func LoadConfig() error {
 
 		// Read JWT secret from environment variable
 		JWTSecretKey = []byte(os.Getenv("JWT_SECRET_KEY"))
+		loadSettings()
 		log.Println("Config successfully loaded for development environment")
 		return nil
 	}
+	loadSettings()
 	log.Println("Config already loaded for production environment")
 	// return fmt.Errorf("nothing loaded in %s environment", env)
 	return nil
 }
+
+// loadSettings reads the settings shared by every environment
+func loadSettings() {
+	OIDCProviders = loadOIDCProviders()
+	Password = loadPasswordPolicy()
+	Hashing = loadPasswordHashing()
+	WebAuthn = loadWebAuthn()
+	Account = loadAccountSettings()
+	Mail = loadMail()
+	URLs = loadURLs()
+}
+
+// getEnvInt reads an integer environment variable, falling back to def when unset or invalid
+func getEnvInt(key string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// getEnvBool reads a boolean environment variable, falling back to def when unset or invalid
+func getEnvBool(key string, def bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return value
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Mail backends
+const (
+	MailBackendSMTP   = "smtp"
+	MailBackendFile   = "file"
+	MailBackendMemory = "memory"
+	MailBackendNone   = "none"
+)
+
+// SMTP transport security modes
+const (
+	SMTPSecurityStartTLS = "starttls"
+	SMTPSecurityTLS      = "tls"
+	SMTPSecurityNone     = "none"
+)
+
+// MailSettings selects and configures the backend outgoing email is handed to
+type MailSettings struct {
+	Backend      string
+	From         string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPSecurity string
+	FileDir      string
+	// Workers is the number of outbox workers delivering queued email
+	Workers int
+	// MaxAttempts is how many times a message is tried before it is dead-lettered
+	MaxAttempts int
+	// SentRetention is how long delivery records are kept after sending or dead-lettering
+	SentRetention time.Duration
+	// DigestInterval is how often the digest job looks for digests that have come due
+	DigestInterval time.Duration
+}
+
+// Mail is the active mail configuration
+var Mail = MailSettings{
+	Backend:        MailBackendNone,
+	From:           "Taskinator <no-reply@localhost>",
+	SMTPPort:       587,
+	SMTPSecurity:   SMTPSecurityStartTLS,
+	FileDir:        "./tmp/mail",
+	Workers:        2,
+	MaxAttempts:    8,
+	SentRetention:  30 * 24 * time.Hour,
+	DigestInterval: 10 * time.Minute,
+}
+
+// loadMail reads the MAIL_* and SMTP_* environment variables. SMTP credentials may also come from
+// the files named by SMTP_USERNAME_FILE and SMTP_PASSWORD_FILE (default /run/secrets/smtp_*).
+// Without MAIL_BACKEND, SMTP is used when a host and credentials are available; otherwise
+// sending is disabled with a warning rather than failing at startup.
+func loadMail() MailSettings {
+	settings := MailSettings{
+		Backend:      strings.ToLower(os.Getenv("MAIL_BACKEND")),
+		From:         os.Getenv("MAIL_FROM"),
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     getEnvInt("SMTP_PORT", 587),
+		SMTPUsername: readEnvOrSecret("SMTP_USERNAME", "/run/secrets/smtp_username"),
+		SMTPPassword: readEnvOrSecret("SMTP_PASSWORD", "/run/secrets/smtp_password"),
+		SMTPSecurity: strings.ToLower(os.Getenv("SMTP_SECURITY")),
+		FileDir:      os.Getenv("MAIL_FILE_DIR"),
+		Workers:      getEnvInt("MAIL_WORKERS", Mail.Workers),
+		MaxAttempts:  getEnvInt("MAIL_MAX_ATTEMPTS", Mail.MaxAttempts),
+	}
+	if settings.Workers < 1 {
+		settings.Workers = Mail.Workers
+	}
+	if settings.MaxAttempts < 1 {
+		settings.MaxAttempts = Mail.MaxAttempts
+	}
+	retentionDays := getEnvInt("MAIL_SENT_RETENTION_DAYS", 30)
+	if retentionDays < 1 {
+		retentionDays = 30
+	}
+	settings.SentRetention = time.Duration(retentionDays) * 24 * time.Hour
+	digestMinutes := getEnvInt("MAIL_DIGEST_INTERVAL_MINUTES", 10)
+	if digestMinutes < 1 {
+		digestMinutes = 10
+	}
+	settings.DigestInterval = time.Duration(digestMinutes) * time.Minute
+	if settings.SMTPHost == "" && settings.SMTPUsername != "" {
+		settings.SMTPHost = "smtp.gmail.com"
+	}
+	if settings.SMTPSecurity != SMTPSecurityTLS && settings.SMTPSecurity != SMTPSecurityNone {
+		settings.SMTPSecurity = SMTPSecurityStartTLS
+	}
+	if settings.FileDir == "" {
+		settings.FileDir = Mail.FileDir
+	}
+	if settings.From == "" {
+		settings.From = settings.SMTPUsername
+	}
+	if settings.From == "" {
+		settings.From = Mail.From
+	}
+
+	switch settings.Backend {
+	case MailBackendSMTP, MailBackendFile, MailBackendMemory, MailBackendNone:
+	case "":
+		settings.Backend = MailBackendNone
+		if settings.SMTPHost != "" {
+			settings.Backend = MailBackendSMTP
+		}
+	default:
+		log.Printf("Unknown MAIL_BACKEND %q; outgoing email is disabled", settings.Backend)
+		settings.Backend = MailBackendNone
+	}
+	return settings
+}
+
+// readEnvOrSecret returns the environment variable key, falling back to the file named by
+// key_FILE or defaultFile. A missing file is not an error.
+func readEnvOrSecret(key, defaultFile string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		path = defaultFile
+	}
+	value, err := ReadSecretFile(path)
+	if err != nil {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+// OIDCProvider holds the client settings for one external identity provider
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// OIDCProviders holds the configured identity providers keyed by name
+var OIDCProviders = map[string]OIDCProvider{}
+
+// loadOIDCProviders reads providers listed in OIDC_PROVIDERS (comma-separated names).
+// Each provider NAME is configured with OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID,
+// OIDC_<NAME>_CLIENT_SECRET, OIDC_<NAME>_REDIRECT_URL and optionally OIDC_<NAME>_SCOPES.
+func loadOIDCProviders() map[string]OIDCProvider {
+	providers := map[string]OIDCProvider{}
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := OIDCProvider{
+			Name:         name,
+			Issuer:       strings.TrimRight(os.Getenv(prefix+"ISSUER"), "/"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       []string{"openid", "email", "profile"},
+		}
+		if scopes := os.Getenv(prefix + "SCOPES"); scopes != "" {
+			provider.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+		}
+
+		if provider.Issuer == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			log.Printf("Skipping OIDC provider %q: issuer, client ID and redirect URL are required", name)
+			continue
+		}
+		providers[name] = provider
+	}
+	return providers
+}
@@ -0,0 +1,95 @@
+package config
+
+import "os"
+
+// bcrypt ignores everything past 72 bytes, so longer passwords would silently lose entropy
+const maxPasswordBytes = 72
+
+// PasswordPolicy holds the rules every new password must satisfy
+type PasswordPolicy struct {
+	MinLength      int
+	MaxBytes       int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSymbol  bool
+	CheckBreached  bool
+	BreachedList   string
+	ForbidIdentity bool
+}
+
+// Password hashing algorithms
+const (
+	HasherBcrypt   = "bcrypt"
+	HasherArgon2id = "argon2id"
+)
+
+// PasswordHashing holds the algorithm and cost parameters used for new password hashes.
+// Stored hashes using anything else are upgraded on the user's next successful login.
+type PasswordHashing struct {
+	Algorithm     string
+	BcryptCost    int
+	Argon2Memory  uint32
+	Argon2Time    uint32
+	Argon2Threads uint8
+}
+
+// Hashing is the active password hashing configuration
+var Hashing = PasswordHashing{
+	Algorithm:     HasherArgon2id,
+	BcryptCost:    10,
+	Argon2Memory:  19 * 1024,
+	Argon2Time:    2,
+	Argon2Threads: 1,
+}
+
+// loadPasswordHashing reads the PASSWORD_HASHER, BCRYPT_* and ARGON2_* environment variables
+func loadPasswordHashing() PasswordHashing {
+	hashing := PasswordHashing{
+		Algorithm:     os.Getenv("PASSWORD_HASHER"),
+		BcryptCost:    getEnvInt("BCRYPT_COST", 10),
+		Argon2Memory:  uint32(getEnvInt("ARGON2_MEMORY_KB", 19*1024)),
+		Argon2Time:    uint32(getEnvInt("ARGON2_TIME", 2)),
+		Argon2Threads: uint8(getEnvInt("ARGON2_THREADS", 1)),
+	}
+	if hashing.Algorithm != HasherBcrypt {
+		hashing.Algorithm = HasherArgon2id
+	}
+	if hashing.BcryptCost < 4 || hashing.BcryptCost > 31 {
+		hashing.BcryptCost = 10
+	}
+	if hashing.Argon2Memory < 8*1024 || hashing.Argon2Time < 1 || hashing.Argon2Threads < 1 {
+		hashing.Argon2Memory, hashing.Argon2Time, hashing.Argon2Threads = 19*1024, 2, 1
+	}
+	return hashing
+}
+
+// Password is the active password policy
+var Password = PasswordPolicy{
+	MinLength:      8,
+	MaxBytes:       maxPasswordBytes,
+	CheckBreached:  true,
+	ForbidIdentity: true,
+}
+
+// loadPasswordPolicy reads the PASSWORD_* environment variables on top of the defaults
+func loadPasswordPolicy() PasswordPolicy {
+	policy := PasswordPolicy{
+		MinLength:      getEnvInt("PASSWORD_MIN_LENGTH", 8),
+		MaxBytes:       getEnvInt("PASSWORD_MAX_BYTES", maxPasswordBytes),
+		RequireUpper:   getEnvBool("PASSWORD_REQUIRE_UPPER", false),
+		RequireLower:   getEnvBool("PASSWORD_REQUIRE_LOWER", false),
+		RequireDigit:   getEnvBool("PASSWORD_REQUIRE_DIGIT", false),
+		RequireSymbol:  getEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+		CheckBreached:  getEnvBool("PASSWORD_CHECK_BREACHED", true),
+		BreachedList:   os.Getenv("PASSWORD_BREACHED_LIST"),
+		ForbidIdentity: getEnvBool("PASSWORD_FORBID_IDENTITY", true),
+	}
+	if policy.MaxBytes <= 0 || policy.MaxBytes > maxPasswordBytes {
+		policy.MaxBytes = maxPasswordBytes
+	}
+	if policy.MinLength < 1 {
+		policy.MinLength = 1
+	}
+	return policy
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"log"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// URLSettings holds the externally reachable addresses used in links sent to users
+type URLSettings struct {
+	// PublicBaseURL is where users reach this server, e.g. https://api.example.com
+	PublicBaseURL string
+	// FrontendURL, when set, receives the password reset, verification and invitation links
+	// instead of the server's own landing pages
+	FrontendURL string
+}
+
+// URLs is the active link configuration
+var URLs = URLSettings{
+	PublicBaseURL: "http://localhost:8080",
+}
+
+// loadURLs reads PUBLIC_BASE_URL and FRONTEND_URL. Without PUBLIC_BASE_URL, links point at
+// localhost on PORT, which only works for development.
+func loadURLs() URLSettings {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	settings := URLSettings{PublicBaseURL: "http://localhost:" + port}
+
+	if base := os.Getenv("PUBLIC_BASE_URL"); base != "" {
+		if normalized, ok := normalizeBaseURL(base); ok {
+			settings.PublicBaseURL = normalized
+		} else {
+			log.Printf("Invalid PUBLIC_BASE_URL %q; using %s", base, settings.PublicBaseURL)
+		}
+	} else {
+		log.Println("PUBLIC_BASE_URL is not set; emailed links will point at", settings.PublicBaseURL)
+	}
+
+	if frontend := os.Getenv("FRONTEND_URL"); frontend != "" {
+		if normalized, ok := normalizeBaseURL(frontend); ok {
+			settings.FrontendURL = normalized
+		} else {
+			log.Printf("Invalid FRONTEND_URL %q; using the built-in landing pages", frontend)
+		}
+	}
+	return settings
+}
+
+// normalizeBaseURL accepts an absolute http(s) URL without query or fragment and drops any trailing slash
+func normalizeBaseURL(raw string) (string, bool) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" ||
+		parsed.RawQuery != "" || parsed.Fragment != "" {
+		return "", false
+	}
+	return strings.TrimRight(parsed.String(), "/"), true
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// WebAuthnSettings identifies this server as a WebAuthn relying party
+type WebAuthnSettings struct {
+	RPID          string
+	RPDisplayName string
+	RPOrigins     []string
+}
+
+// WebAuthn is the active relying party configuration
+var WebAuthn = WebAuthnSettings{
+	RPID:          "localhost",
+	RPDisplayName: "Taskinator",
+	RPOrigins:     []string{"http://localhost:3000"},
+}
+
+// loadWebAuthn reads WEBAUTHN_RP_ID, WEBAUTHN_RP_NAME and WEBAUTHN_RP_ORIGINS (comma-separated)
+func loadWebAuthn() WebAuthnSettings {
+	settings := WebAuthn
+	if rpID := os.Getenv("WEBAUTHN_RP_ID"); rpID != "" {
+		settings.RPID = rpID
+	}
+	if name := os.Getenv("WEBAUTHN_RP_NAME"); name != "" {
+		settings.RPDisplayName = name
+	}
+	if origins := os.Getenv("WEBAUTHN_RP_ORIGINS"); origins != "" {
+		settings.RPOrigins = nil
+		for _, origin := range strings.Split(origins, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				settings.RPOrigins = append(settings.RPOrigins, origin)
+			}
+		}
+	}
+	return settings
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/dto"
+	"github.com/wanloq/taskinator/internal/models"
+	"github.com/wanloq/taskinator/internal/repositories"
+	"github.com/wanloq/taskinator/internal/utils"
+)
+
+// @Summary Delete own account
+// @Description DeleteOwnAccount schedules the authenticated user's account for deletion after the grace period.
+// @Description The password must be re-entered unless the session logged in within the last few minutes, so
+// @Description accounts without a password confirm by signing in again. Logging in again before the deletion date cancels it.
+// @Tags Delete Profile
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body dto.DeleteAccountRequest false "Current password"
+// @Success 202 {object} map[string]string "Deletion scheduled"
+// @Failure 400 {object} map[string]string "Invalid request body"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /user/me [delete]
+func DeleteOwnAccount(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil || principal.IsDelegated() {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var req dto.DeleteAccountRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+
+	user, err := repositories.GetUserByID(principal.UserID)
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+	if err := confirmRecentLogin(principal, user, req.Password, "Enter your password or log in again to delete your account"); err != nil {
+		return c.Status(err.Code).JSON(fiber.Map{"error": err.Message})
+	}
+
+	deleteAt := time.Now().Add(config.Account.DeletionGracePeriod)
+	if err := repositories.ScheduleUserDeletion(user.ID, deleteAt); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not schedule deletion"})
+	}
+
+	go func() {
+		if err := utils.SendAccountDeletionScheduledEmail(user, deleteAt); err != nil {
+			log.Println("Could not send deletion notice to ", user.Email, err)
+		}
+	}()
+
+	return c.Status(http.StatusAccepted).JSON(fiber.Map{
+		"message":               "Your account will be deleted. Log in again before the deletion date to cancel.",
+		"deletion_scheduled_at": deleteAt,
+	})
+}
+
+// confirmRecentLogin checks that the caller proved who they are before a sensitive change, either by
+// re-entering their password or by having logged in within the re-authentication window. Accounts
+// without a password (single sign-on, passkeys, imports) confirm by logging in again.
+func confirmRecentLogin(principal *dto.Principal, user *models.User, password, loginAgainMessage string) *fiber.Error {
+	switch {
+	case password != "":
+		if !utils.ComparePasswords(user.PasswordHash, password) {
+			return fiber.NewError(http.StatusUnauthorized, "Invalid credentials")
+		}
+	case time.Since(principal.AuthenticatedAt) > config.Account.ReauthWindow:
+		return fiber.NewError(http.StatusUnauthorized, loginAgainMessage)
+	}
+	return nil
+}
+
+// @Summary Export own data
+// @Description ExportAccountData returns everything stored about the authenticated user as a JSON download
+// @Tags Profile
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} dto.AccountExport "Account data"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /user/me/export [get]
+func ExportAccountData(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil || principal.IsDelegated() {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	user, err := repositories.GetUserByID(principal.UserID)
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+	identities, err := repositories.GetUserIdentitiesByUser(user.ID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not export data"})
+	}
+	passkeys, err := repositories.GetWebAuthnCredentialsByUser(user.ID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not export data"})
+	}
+	tokens, err := repositories.GetPersonalAccessTokensByUser(user.ID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not export data"})
+	}
+	grants, err := repositories.GetResourceGrantsByUser(user.ID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not export data"})
+	}
+
+	c.Attachment(fmt.Sprintf("taskinator-export-%d.json", user.ID))
+	return c.JSON(dto.AccountExport{
+		ExportedAt:     time.Now(),
+		Profile:        profileResponse(user),
+		CreatedAt:      user.CreatedAt,
+		AccountStatus:  user.EffectiveStatus(time.Now()),
+		StatusUntil:    user.StatusUntil,
+		Identities:     identities,
+		Passkeys:       passkeys,
+		AccessTokens:   tokens,
+		ResourceGrants: grants,
+	})
+}
+
+// loginBlockedReason explains why an otherwise authenticated user may not log in, or returns ""
+func loginBlockedReason(user *models.User) string {
+	if !user.IsBlocked(time.Now()) {
+		return ""
+	}
+
+	message := "This account has been suspended"
+	if user.Status == models.UserStatusLocked {
+		message = "This account has been locked for security reasons"
+	}
+	if user.StatusUntil != nil {
+		message += " until " + user.StatusUntil.UTC().Format(time.RFC1123)
+	}
+	if user.StatusReason != "" {
+		message += ": " + user.StatusReason
+	}
+	return message + ". Please contact support."
+}
+
+// cancelScheduledDeletion is called on every successful login: coming back cancels a pending self-deletion
+func cancelScheduledDeletion(user *models.User) {
+	if user.Status != models.UserStatusPendingDeletion {
+		return
+	}
+	cancelled, err := repositories.CancelUserDeletion(user.ID)
+	if err != nil {
+		log.Println("Could not cancel scheduled deletion for user", user.ID, err)
+		return
+	}
+	if cancelled {
+		user.Status, user.StatusReason, user.StatusUntil = models.UserStatusActive, "", nil
+		log.Println("Scheduled deletion cancelled by login for user", user.ID)
+	}
+}
@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wanloq/taskinator/internal/dto"
+	"github.com/wanloq/taskinator/internal/models"
+	"github.com/wanloq/taskinator/internal/repositories"
+)
+
+// @Summary List roles
+// @Description ListRoles returns every role together with the permissions it grants
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.Role "Roles"
+// @Failure 403 {object} map[string]string "Access denied"
+// @Router /api/admin/roles [get]
+func ListRoles(c *fiber.Ctx) error {
+	roles, err := repositories.GetAllRoles()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not load roles"})
+	}
+	return c.JSON(roles)
+}
+
+// @Summary List permissions
+// @Description ListPermissions returns every permission that can be granted to a role
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.Permission "Permissions"
+// @Failure 403 {object} map[string]string "Access denied"
+// @Router /api/admin/permissions [get]
+func ListPermissions(c *fiber.Ctx) error {
+	permissions, err := repositories.GetAllPermissions()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not load permissions"})
+	}
+	return c.JSON(permissions)
+}
+
+// @Summary Create role
+// @Description CreateRole creates a new role with the given permission set
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body dto.CreateRoleRequest true "Role definition"
+// @Success 201 {object} models.Role "Role created"
+// @Failure 400 {object} map[string]string "Invalid request body"
+// @Failure 403 {object} map[string]string "Access denied"
+// @Failure 500 {object} map[string]string "Could not create role"
+// @Router /api/admin/roles [post]
+func CreateRole(c *fiber.Ctx) error {
+	var req dto.CreateRoleRequest
+	if err := c.BodyParser(&req); err != nil || req.Name == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	if _, err := repositories.GetRoleByName(req.Name); err == nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Role already exists"})
+	}
+
+	permissions, lookupErr := lookupPermissions(req.Permissions)
+	if lookupErr != nil {
+		return c.Status(lookupErr.Code).JSON(fiber.Map{"error": lookupErr.Message})
+	}
+
+	role := models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: permissions,
+	}
+	if err := repositories.CreateRole(&role); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create role"})
+	}
+
+	return c.Status(http.StatusCreated).JSON(role)
+}
+
+// @Summary Update role permissions
+// @Description UpdateRolePermissions replaces the permission set granted by a role
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param name path string true "Role name"
+// @Param request body dto.UpdateRolePermissionsRequest true "Permission names"
+// @Success 200 {object} models.Role "Role updated"
+// @Failure 400 {object} map[string]string "Invalid request body"
+// @Failure 404 {object} map[string]string "Role not found"
+// @Failure 500 {object} map[string]string "Could not update role"
+// @Router /api/admin/roles/{name}/permissions [put]
+func UpdateRolePermissions(c *fiber.Ctx) error {
+	var req dto.UpdateRolePermissionsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	role, err := repositories.GetRoleByName(c.Params("name"))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Role not found"})
+	}
+
+	permissions, lookupErr := lookupPermissions(req.Permissions)
+	if lookupErr != nil {
+		return c.Status(lookupErr.Code).JSON(fiber.Map{"error": lookupErr.Message})
+	}
+
+	if err := repositories.ReplaceRolePermissions(role, permissions); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update role"})
+	}
+
+	role.Permissions = permissions
+	return c.JSON(role)
+}
+
+// @Summary Assign role
+// @Description AssignUserRole changes a user's role; the change applies to their next request
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param request body dto.AssignRoleRequest true "Role name"
+// @Success 200 {object} map[string]string "Role assigned"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 404 {object} map[string]string "User not found"
+// @Router /api/admin/users/{id}/role [put]
+func AssignUserRole(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	var req dto.AssignRoleRequest
+	if err := c.BodyParser(&req); err != nil || req.Role == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	if _, err := repositories.GetRoleByName(req.Role); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Unknown role"})
+	}
+
+	user, err := repositories.GetUserByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	if err := repositories.UpdateUserRole(user.ID, req.Role); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not assign role"})
+	}
+	recordAudit(c, models.AuditUserRoleChanged, user.ID, fmt.Sprintf("%s -> %s", user.Role, req.Role))
+
+	return c.JSON(fiber.Map{"message": "Role assigned successfully"})
+}
+
+// @Summary Unlock account
+// @Description UnlockUserAccount clears failed-login lockouts for a user's account
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string "Account unlocked"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 404 {object} map[string]string "User not found"
+// @Router /api/admin/users/{id}/unlock [post]
+func UnlockUserAccount(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	user, err := repositories.GetUserByID(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	if err := repositories.ClearLoginThrottle(models.ThrottleScopeAccount, loginThrottleKey(user.Email)); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not unlock account"})
+	}
+	recordAudit(c, models.AuditUserUnlocked, user.ID, "")
+
+	return c.JSON(fiber.Map{"message": "Account unlocked successfully"})
+}
+
+// lookupPermissions resolves permission names, rejecting any that do not exist
+func lookupPermissions(names []string) ([]models.Permission, *fiber.Error) {
+	permissions, err := repositories.GetPermissionsByNames(names)
+	if err != nil {
+		return nil, fiber.NewError(http.StatusInternalServerError, "Could not load permissions")
+	}
+
+	found := make(map[string]bool, len(permissions))
+	for _, p := range permissions {
+		found[p.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, fiber.NewError(http.StatusBadRequest, "Unknown permission: "+name)
+		}
+	}
+	return permissions, nil
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wanloq/taskinator/internal/dto"
+	"github.com/wanloq/taskinator/internal/models"
+	"github.com/wanloq/taskinator/internal/repositories"
+)
+
+// @Summary Email queue statistics
+// @Description GetOutboxStats returns the email queue depth, retrying and dead-lettered counts, and the age of the oldest undelivered message
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} repositories.OutboxStats "Queue statistics"
+// @Failure 403 {object} map[string]string "Access denied"
+// @Router /api/admin/email/outbox/stats [get]
+func GetOutboxStats(c *fiber.Ctx) error {
+	stats, err := repositories.GetOutboxStats()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not load email statistics"})
+	}
+	return c.JSON(stats)
+}
+
+// @Summary List queued email
+// @Description ListOutboxEmails returns outbox messages, newest first, with their delivery attempts and last error
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param status query string false "pending, sending, sent or dead"
+// @Param page query int false "Page number, from 1"
+// @Param page_size query int false "Results per page, at most 100"
+// @Success 200 {object} dto.EmailOutboxListResponse "Messages"
+// @Failure 400 {object} map[string]string "Invalid status"
+// @Failure 403 {object} map[string]string "Access denied"
+// @Router /api/admin/email/outbox [get]
+func ListOutboxEmails(c *fiber.Ctx) error {
+	status := c.Query("status")
+	switch status {
+	case "", models.OutboxPending, models.OutboxSending, models.OutboxSent, models.OutboxDead:
+	default:
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid status"})
+	}
+
+	page, pageSize := parsePagination(c)
+	messages, total, err := repositories.ListOutboxEmails(status, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not load email queue"})
+	}
+	return c.JSON(dto.EmailOutboxListResponse{Messages: messages, Page: page, PageSize: pageSize, Total: total})
+}
+
+// @Summary Retry dead-lettered email
+// @Description RetryOutboxEmail puts a dead-lettered message back in the queue with a fresh set of attempts.
+// @Description Messages whose link has expired cannot be retried; the user has to request a new one.
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Message ID"
+// @Success 200 {object} map[string]string "Message requeued"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 404 {object} map[string]string "No dead-lettered message with this ID"
+// @Failure 409 {object} map[string]string "Link in the message has expired"
+// @Router /api/admin/email/outbox/{id}/retry [post]
+func RetryOutboxEmail(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	requeued, err := repositories.RequeueDeadEmail(uint(id))
+	if errors.Is(err, repositories.ErrEmailExpired) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "The link in this email has expired; the user must request a new one"})
+	}
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not requeue email"})
+	}
+	if requeued == 0 {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "No dead-lettered message with this ID"})
+	}
+	recordAudit(c, models.AuditEmailRequeued, 0, fmt.Sprintf("email %d", id))
+
+	return c.JSON(fiber.Map{"message": "Email requeued"})
+}
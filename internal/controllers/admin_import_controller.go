@@ -0,0 +1,421 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wanloq/taskinator/internal/dto"
+	"github.com/wanloq/taskinator/internal/models"
+	"github.com/wanloq/taskinator/internal/repositories"
+	"github.com/wanloq/taskinator/internal/utils"
+)
+
+// Bulk import and export limits
+const (
+	maxImportRows   = 1000
+	exportBatchSize = 500
+	invitationTTL   = 7 * 24 * time.Hour
+)
+
+// Import row statuses
+const (
+	importStatusValid   = "valid"
+	importStatusCreated = "created"
+	importStatusError   = "error"
+)
+
+// importedPasswordHash is stored for imported users until they accept their invitation or reset their password.
+// No password hasher recognises it, so no password matches.
+const importedPasswordHash = "!imported"
+
+var exportColumns = []string{
+	"id", "username", "email", "role", "is_verified", "display_name", "timezone", "locale",
+	"status", "status_reason", "status_until", "created_at", "updated_at", "deleted_at",
+}
+
+// @Summary Import users
+// @Description ImportUsers creates users from a CSV file (header: username,email[,role][,display_name]) or a JSON array.
+// @Description Every row is validated and reported individually; with dry_run nothing is created.
+// @Description Imported users have no password until they follow their invitation or reset their password.
+// @Description Rows with a role other than "user" also need the roles:manage permission.
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Accept text/csv
+// @Produce json
+// @Param format query string false "csv or json; defaults to the request Content-Type"
+// @Param dry_run query bool false "Validate only"
+// @Param send_invites query bool false "Email each created user an invitation link"
+// @Success 200 {object} dto.ImportUsersResponse "Import report"
+// @Failure 400 {object} map[string]string "Unreadable file"
+// @Failure 403 {object} map[string]string "Access denied"
+// @Router /api/admin/users/import [post]
+func ImportUsers(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	// Importing must not become a way to hand out roles the caller could not assign directly
+	canAssignRoles := principal.HasPermission(models.PermRolesManage)
+
+	dryRun := c.QueryBool("dry_run")
+	sendInvites := c.QueryBool("send_invites")
+
+	format := c.Query("format")
+	if format == "" {
+		format = "json"
+		if strings.HasPrefix(c.Get(fiber.HeaderContentType), "text/csv") {
+			format = "csv"
+		}
+	}
+
+	var rows []dto.ImportUserRow
+	switch format {
+	case "csv":
+		rows, err = parseImportCSV(c.Body())
+	case "json":
+		err = json.Unmarshal(c.Body(), &rows)
+	default:
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "format must be csv or json"})
+	}
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Could not read %s: %v", format, err)})
+	}
+	if len(rows) == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "No users to import"})
+	}
+	if len(rows) > maxImportRows {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("At most %d users can be imported at once", maxImportRows)})
+	}
+
+	roles, err := repositories.GetAllRoles()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not load roles"})
+	}
+	knownRoles := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		knownRoles[role.Name] = true
+	}
+
+	report := dto.ImportUsersResponse{DryRun: dryRun, Total: len(rows), Rows: make([]dto.ImportRowResult, 0, len(rows))}
+	seenEmails := map[string]int{}
+	seenUsernames := map[string]int{}
+	var invites []models.User
+
+	for i, row := range rows {
+		result := dto.ImportRowResult{
+			Row:      i + 1,
+			Username: strings.TrimSpace(row.Username),
+			Email:    strings.TrimSpace(row.Email),
+		}
+		role := strings.TrimSpace(row.Role)
+		if role == "" {
+			role = models.RoleUser
+		}
+		displayName := strings.TrimSpace(row.DisplayName)
+
+		result.Errors = validateImportRow(result.Username, result.Email, role, displayName, knownRoles, canAssignRoles)
+		if first, ok := seenEmails[strings.ToLower(result.Email)]; ok && result.Email != "" {
+			result.Errors = append(result.Errors, fmt.Sprintf("email duplicates row %d", first))
+		} else {
+			seenEmails[strings.ToLower(result.Email)] = result.Row
+		}
+		if first, ok := seenUsernames[result.Username]; ok && result.Username != "" {
+			result.Errors = append(result.Errors, fmt.Sprintf("username duplicates row %d", first))
+		} else {
+			seenUsernames[result.Username] = result.Row
+		}
+
+		switch {
+		case len(result.Errors) > 0:
+			result.Status = importStatusError
+		case dryRun:
+			result.Status = importStatusValid
+		default:
+			user := models.User{
+				Username:     result.Username,
+				Email:        result.Email,
+				PasswordHash: importedPasswordHash,
+				Role:         role,
+				DisplayName:  displayName,
+			}
+			if err := repositories.CreateUser(&user); err != nil {
+				result.Status = importStatusError
+				result.Errors = []string{"could not create user"}
+				break
+			}
+			result.Status = importStatusCreated
+			result.UserID = user.ID
+			recordAudit(c, models.AuditUserImported, user.ID, "")
+			if sendInvites {
+				invites = append(invites, user)
+			}
+		}
+
+		switch result.Status {
+		case importStatusError:
+			report.Failed++
+		case importStatusCreated:
+			report.Valid++
+			report.Created++
+		default:
+			report.Valid++
+		}
+		report.Rows = append(report.Rows, result)
+	}
+
+	if len(invites) > 0 {
+		go sendInvitations(invites)
+	}
+	return c.JSON(report)
+}
+
+// @Summary Export users
+// @Description ExportUsers streams every user matching the listing filters as CSV or JSON. Password hashes are never included.
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Produce text/csv
+// @Param format query string false "csv (default) or json"
+// @Param role query string false "Role name"
+// @Param verified query bool false "Email verified"
+// @Param status query string false "active, suspended, locked or pending_deletion"
+// @Param deleted query string false "Deleted users: exclude (default), include or only"
+// @Param created_after query string false "RFC 3339 timestamp or YYYY-MM-DD"
+// @Param created_before query string false "RFC 3339 timestamp or YYYY-MM-DD"
+// @Param q query string false "Username or email substring"
+// @Success 200 {file} binary "User export"
+// @Failure 400 {object} map[string]string "Invalid filter"
+// @Router /api/admin/users/export [get]
+func ExportUsers(c *fiber.Ctx) error {
+	filter, err := parseUserFilter(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	format := c.Query("format", "csv")
+	if format != "csv" && format != "json" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "format must be csv or json"})
+	}
+	recordAudit(c, models.AuditUsersExported, 0, fmt.Sprintf("format=%s %s", format, c.Context().QueryArgs().String()))
+
+	c.Attachment(fmt.Sprintf("taskinator-users-%s.%s", time.Now().UTC().Format("20060102-150405"), format))
+	if format == "csv" {
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	} else {
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	}
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		var err error
+		if format == "csv" {
+			err = streamUsersCSV(w, filter)
+		} else {
+			err = streamUsersJSON(w, filter)
+		}
+		if err != nil {
+			log.Println("User export aborted:", err)
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// @Summary Accept invitation
+// @Description AcceptInvitation sets the password of an imported user and verifies their email
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body dto.ResetRequest true "Invitation token and new password"
+// @Success 200 {object} map[string]string "Account activated"
+// @Failure 400 {object} map[string]string "Password rejected"
+// @Failure 401 {object} map[string]string "Invalid or expired token"
+// @Router /user/invitation/accept [post]
+func AcceptInvitation(c *fiber.Ctx) error {
+	var req dto.ResetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := setPasswordWithToken(req.Token, req.NewPassword, models.TokenPurposeInvitation); err != nil {
+		return c.Status(err.Code).JSON(fiber.Map{"error": err.Message})
+	}
+
+	return c.JSON(fiber.Map{"message": "Account activated. You may now log in."})
+}
+
+// parseImportCSV reads import rows from CSV with a header naming the columns
+func parseImportCSV(data []byte) ([]dto.ImportUserRow, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, errors.New("header must include an email column")
+	}
+	if _, ok := columns["username"]; !ok {
+		return nil, errors.New("header must include a username column")
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	var rows []dto.ImportUserRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, dto.ImportUserRow{
+			Username:    field(record, "username"),
+			Email:       field(record, "email"),
+			Role:        field(record, "role"),
+			DisplayName: field(record, "display_name"),
+		})
+		if len(rows) > maxImportRows {
+			return rows, nil
+		}
+	}
+}
+
+// validateImportRow returns every problem with one imported user
+func validateImportRow(username, email, role, displayName string, knownRoles map[string]bool, canAssignRoles bool) []string {
+	var problems []string
+	if username == "" || len(username) > maxUsernameLength {
+		problems = append(problems, fmt.Sprintf("username must be 1 to %d characters", maxUsernameLength))
+	} else if repositories.UsernameExists(username) {
+		problems = append(problems, "username already in use")
+	}
+	if address, err := mail.ParseAddress(email); err != nil || address.Address != email {
+		problems = append(problems, "email must be a valid email address")
+	} else if repositories.EmailExists(email) {
+		problems = append(problems, "email already in use")
+	}
+	if !knownRoles[role] {
+		problems = append(problems, fmt.Sprintf("unknown role %q", role))
+	} else if role != models.RoleUser && !canAssignRoles {
+		problems = append(problems, fmt.Sprintf("assigning role %q requires the %s permission", role, models.PermRolesManage))
+	}
+	if len([]rune(displayName)) > maxDisplayNameLength {
+		problems = append(problems, fmt.Sprintf("display_name must be at most %d characters", maxDisplayNameLength))
+	}
+	return problems
+}
+
+// sendInvitations emails each imported user a link to choose their password
+func sendInvitations(users []models.User) {
+	for _, user := range users {
+		token, err := issueOneTimeToken(user.ID, models.TokenPurposeInvitation, invitationTTL)
+		if err != nil {
+			log.Println("Could not generate invitation token for ", user.Email, err)
+			continue
+		}
+		if err := utils.SendInvitationEmail(&user, token, invitationTTL); err != nil {
+			log.Println("Could not send invitation to ", user.Email, err)
+		}
+	}
+}
+
+// streamUsersCSV writes the users matching the filter as CSV, one batch at a time
+func streamUsersCSV(w io.Writer, filter repositories.UserFilter) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(exportColumns); err != nil {
+		return err
+	}
+	err := repositories.EachUserBatch(filter, exportBatchSize, func(users []models.User) error {
+		for i := range users {
+			u := adminUserResponse(&users[i])
+			record := []string{
+				strconv.FormatUint(uint64(u.ID), 10), u.Username, u.Email, u.Role, strconv.FormatBool(u.IsVerified),
+				u.DisplayName, u.Timezone, u.Locale, u.Status, u.StatusReason, formatExportTime(u.StatusUntil),
+				formatExportTime(&u.CreatedAt), formatExportTime(&u.UpdatedAt), formatExportTime(u.DeletedAt),
+			}
+			for j := range record {
+				record[j] = csvSafe(record[j])
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	})
+	writer.Flush()
+	if err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// streamUsersJSON writes the users matching the filter as a JSON array, one batch at a time
+func streamUsersJSON(w io.Writer, filter repositories.UserFilter) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	first := true
+	err := repositories.EachUserBatch(filter, exportBatchSize, func(users []models.User) error {
+		for i := range users {
+			data, err := json.Marshal(adminUserResponse(&users[i]))
+			if err != nil {
+				return err
+			}
+			if !first {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			first = false
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "]")
+	return err
+}
+
+// formatExportTime renders an optional timestamp for CSV
+func formatExportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// csvSafe stops spreadsheet applications from evaluating user-controlled cells as formulas
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
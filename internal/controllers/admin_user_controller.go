@@ -0,0 +1,538 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wanloq/taskinator/internal/dto"
+	"github.com/wanloq/taskinator/internal/models"
+	"github.com/wanloq/taskinator/internal/repositories"
+	"github.com/wanloq/taskinator/internal/utils"
+	"gorm.io/gorm"
+)
+
+// Admin listing page sizes
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// Impersonation token lifetimes
+const (
+	defaultImpersonationMinutes = 15
+	maxImpersonationMinutes     = 60
+)
+
+// @Summary List users
+// @Description ListUsers returns a filtered, paginated list of users
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param role query string false "Role name"
+// @Param verified query bool false "Email verified"
+// @Param status query string false "active, suspended, locked or pending_deletion"
+// @Param deleted query string false "Deleted users: exclude (default), include or only"
+// @Param created_after query string false "RFC 3339 timestamp or YYYY-MM-DD"
+// @Param created_before query string false "RFC 3339 timestamp or YYYY-MM-DD"
+// @Param q query string false "Username or email substring"
+// @Param page query int false "Page number, from 1"
+// @Param page_size query int false "Results per page, at most 100"
+// @Success 200 {object} dto.UserListResponse "Users"
+// @Failure 400 {object} map[string]string "Invalid filter"
+// @Failure 403 {object} map[string]string "Access denied"
+// @Router /api/admin/users [get]
+func ListUsers(c *fiber.Ctx) error {
+	page, pageSize := parsePagination(c)
+	filter, err := parseUserFilter(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	filter.Offset = (page - 1) * pageSize
+	filter.Limit = pageSize
+
+	users, total, err := repositories.ListUsers(filter)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not load users"})
+	}
+
+	response := dto.UserListResponse{
+		Users:    make([]dto.AdminUserResponse, 0, len(users)),
+		Page:     page,
+		PageSize: pageSize,
+		Total:    total,
+	}
+	for i := range users {
+		response.Users = append(response.Users, adminUserResponse(&users[i]))
+	}
+	return c.JSON(response)
+}
+
+// @Summary Get user
+// @Description GetUser returns any user by ID, including deleted users
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} dto.AdminUserResponse "User"
+// @Failure 404 {object} map[string]string "User not found"
+// @Router /api/admin/users/{id} [get]
+func GetUser(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	user, err := repositories.GetUserByIDUnscoped(uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+	return c.JSON(adminUserResponse(user))
+}
+
+// @Summary Force-verify user
+// @Description VerifyUser marks a user's email address as verified
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string "User verified"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 403 {object} map[string]string "User holds permissions the caller lacks"
+// @Router /api/admin/users/{id}/verify [post]
+func VerifyUser(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	user, lookupErr := adminTargetUser(c)
+	if lookupErr != nil {
+		return c.Status(lookupErr.Code).JSON(fiber.Map{"error": lookupErr.Message})
+	}
+	if refusal := authorizeAdminTarget(c, principal, user, "verify"); refusal != nil {
+		return c.Status(refusal.Code).JSON(fiber.Map{"error": refusal.Message})
+	}
+
+	if !user.IsVerified {
+		if err := repositories.UpdateUserFields(user.ID, map[string]interface{}{"is_verified": true}, nil); err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not verify user"})
+		}
+	}
+	recordAudit(c, models.AuditUserVerified, user.ID, "")
+
+	return c.JSON(fiber.Map{"message": "User verified successfully"})
+}
+
+// @Summary Suspend user
+// @Description SuspendUser blocks a user from logging in and from using existing sessions and tokens.
+// @Description Without until the suspension lasts until the user is reinstated. The user is notified by email.
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param request body dto.SuspendUserRequest false "Reason and end of the suspension"
+// @Success 200 {object} dto.AdminUserResponse "User suspended"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 403 {object} map[string]string "User holds permissions the caller lacks"
+// @Router /api/admin/users/{id}/suspend [post]
+func SuspendUser(c *fiber.Ctx) error {
+	return blockUser(c, models.UserStatusSuspended, models.AuditUserSuspended)
+}
+
+// @Summary Lock user
+// @Description LockUser blocks a user like SuspendUser, as a security measure such as a suspected compromise
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param request body dto.SuspendUserRequest false "Reason and end of the lock"
+// @Success 200 {object} dto.AdminUserResponse "User locked"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 403 {object} map[string]string "User holds permissions the caller lacks"
+// @Router /api/admin/users/{id}/lock [post]
+func LockUser(c *fiber.Ctx) error {
+	return blockUser(c, models.UserStatusLocked, models.AuditUserLocked)
+}
+
+// @Summary Reinstate user
+// @Description ReinstateUser makes a suspended, locked or pending-deletion account active again and notifies the user
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} dto.AdminUserResponse "User reinstated"
+// @Failure 404 {object} map[string]string "User not found"
+// @Router /api/admin/users/{id}/reinstate [post]
+func ReinstateUser(c *fiber.Ctx) error {
+	user, lookupErr := adminTargetUser(c)
+	if lookupErr != nil {
+		return c.Status(lookupErr.Code).JSON(fiber.Map{"error": lookupErr.Message})
+	}
+
+	previous := user.EffectiveStatus(time.Now())
+	if err := repositories.SetUserStatus(user.ID, models.UserStatusActive, "", nil); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not reinstate user"})
+	}
+	recordAudit(c, models.AuditUserReinstated, user.ID, "was "+previous)
+
+	if previous != models.UserStatusActive {
+		go func() {
+			if err := utils.SendAccountReinstatedEmail(user); err != nil {
+				log.Println("Could not send reinstatement notice to ", user.Email, err)
+			}
+		}()
+	}
+
+	user.Status, user.StatusReason, user.StatusUntil = models.UserStatusActive, "", nil
+	return c.JSON(adminUserResponse(user))
+}
+
+// blockUser suspends or locks the target user and notifies them
+func blockUser(c *fiber.Ctx, status, action string) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	user, lookupErr := adminTargetUser(c)
+	if lookupErr != nil {
+		return c.Status(lookupErr.Code).JSON(fiber.Map{"error": lookupErr.Message})
+	}
+	if principal.UserID == user.ID || principal.ActorID == user.ID {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "You cannot suspend or lock your own account"})
+	}
+	if refusal := authorizeAdminTarget(c, principal, user, status); refusal != nil {
+		return c.Status(refusal.Code).JSON(fiber.Map{"error": refusal.Message})
+	}
+
+	var req dto.SuspendUserRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Until != nil && !req.Until.After(time.Now()) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "until must be in the future"})
+	}
+
+	if err := repositories.SetUserStatus(user.ID, status, req.Reason, req.Until); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update user status"})
+	}
+	details := req.Reason
+	if req.Until != nil {
+		details = fmt.Sprintf("%s (until %s)", req.Reason, req.Until.UTC().Format(time.RFC3339))
+	}
+	recordAudit(c, action, user.ID, details)
+
+	go func() {
+		if err := utils.SendAccountSuspendedEmail(user, status, req.Reason, req.Until); err != nil {
+			log.Println("Could not send", status, "notice to ", user.Email, err)
+		}
+	}()
+
+	user.Status, user.StatusReason, user.StatusUntil = status, req.Reason, req.Until
+	return c.JSON(adminUserResponse(user))
+}
+
+// @Summary Trigger password reset
+// @Description TriggerPasswordReset emails the user a password reset link
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 202 {object} map[string]string "Reset link sent"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 403 {object} map[string]string "User holds permissions the caller lacks"
+// @Router /api/admin/users/{id}/password-reset [post]
+func TriggerPasswordReset(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	user, lookupErr := adminTargetUser(c)
+	if lookupErr != nil {
+		return c.Status(lookupErr.Code).JSON(fiber.Map{"error": lookupErr.Message})
+	}
+	if refusal := authorizeAdminTarget(c, principal, user, "password reset"); refusal != nil {
+		return c.Status(refusal.Code).JSON(fiber.Map{"error": refusal.Message})
+	}
+
+	resetToken, err := issueOneTimeToken(user.ID, models.TokenPurposePasswordReset, utils.OneTimeTokenTTL)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not generate reset token"})
+	}
+	go func() {
+		if err := utils.SendPasswordResetEmail(user, resetToken); err != nil {
+			log.Println("Could not send password reset email to ", user.Email, err)
+		}
+	}()
+	recordAudit(c, models.AuditUserPasswordReset, user.ID, "")
+
+	return c.Status(http.StatusAccepted).JSON(fiber.Map{"message": "Password reset link sent"})
+}
+
+// @Summary List audit log
+// @Description ListAuditLogs returns recorded administrative actions, newest first
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param actor_id query int false "Acting user ID"
+// @Param target_id query int false "Target user ID"
+// @Param action query string false "Action, e.g. user.disabled"
+// @Param page query int false "Page number, from 1"
+// @Param page_size query int false "Results per page, at most 100"
+// @Success 200 {object} dto.AuditLogListResponse "Audit entries"
+// @Failure 403 {object} map[string]string "Access denied"
+// @Router /api/admin/audit-logs [get]
+func ListAuditLogs(c *fiber.Ctx) error {
+	page, pageSize := parsePagination(c)
+	filter := repositories.AuditLogFilter{
+		ActorID: uint(c.QueryInt("actor_id")),
+		Action:  c.Query("action"),
+		Offset:  (page - 1) * pageSize,
+		Limit:   pageSize,
+	}
+	if targetID := c.QueryInt("target_id"); targetID > 0 {
+		filter.TargetType = models.AuditTargetUser
+		filter.TargetID = uint(targetID)
+	}
+
+	entries, total, err := repositories.ListAuditLogs(filter)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not load audit log"})
+	}
+	return c.JSON(dto.AuditLogListResponse{Entries: entries, Page: page, PageSize: pageSize, Total: total})
+}
+
+// @Summary Impersonate user
+// @Description ImpersonateUser issues a short-lived token that acts as the user, for support.
+// @Description Requests made with it carry X-Impersonated-By and X-Impersonated-User response headers,
+// @Description are read-only unless allow_writes is set, and are recorded in the audit log under the administrator.
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param request body dto.ImpersonateRequest false "Token options"
+// @Success 200 {object} map[string]interface{} "Impersonation token"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 403 {object} map[string]string "Impersonation not allowed, e.g. the user holds permissions the caller lacks"
+// @Failure 404 {object} map[string]string "User not found"
+// @Router /api/admin/users/{id}/impersonate [post]
+func ImpersonateUser(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	if principal.IsDelegated() {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "Impersonation must be started from your own login session"})
+	}
+
+	user, lookupErr := adminTargetUser(c)
+	if lookupErr != nil {
+		return c.Status(lookupErr.Code).JSON(fiber.Map{"error": lookupErr.Message})
+	}
+	if user.ID == principal.UserID {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "You cannot impersonate yourself"})
+	}
+	if reason := loginBlockedReason(user); reason != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Suspended or locked accounts cannot be impersonated"})
+	}
+	// Acting as a more privileged user would let the caller borrow permissions they do not hold
+	if refusal := authorizeAdminTarget(c, principal, user, "impersonate"); refusal != nil {
+		return c.Status(refusal.Code).JSON(fiber.Map{"error": refusal.Message})
+	}
+
+	var req dto.ImpersonateRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+	minutes := req.Minutes
+	if minutes <= 0 {
+		minutes = defaultImpersonationMinutes
+	}
+	if minutes > maxImpersonationMinutes {
+		minutes = maxImpersonationMinutes
+	}
+
+	token, expiresAt, err := utils.GenerateImpersonationJWT(user.ID, user.Email, user.Role, principal.UserID, time.Duration(minutes)*time.Minute, !req.AllowWrites)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create impersonation token"})
+	}
+	recordAudit(c, models.AuditImpersonationStarted, user.ID, fmt.Sprintf("%d minutes, writes allowed: %t", minutes, req.AllowWrites))
+
+	return c.JSON(fiber.Map{
+		"token":      token,
+		"expires_at": expiresAt,
+		"read_only":  !req.AllowWrites,
+	})
+}
+
+// adminTargetUser loads the user named by the :id path parameter
+func adminTargetUser(c *fiber.Ctx) (*models.User, *fiber.Error) {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return nil, fiber.NewError(http.StatusBadRequest, "Invalid ID")
+	}
+	user, err := repositories.GetUserByID(uint(id))
+	if err != nil {
+		return nil, fiber.NewError(http.StatusNotFound, "User not found")
+	}
+	return user, nil
+}
+
+// authorizeAdminTarget refuses to let the caller act on a user whose role holds a permission the
+// caller lacks, so nobody can suspend, reset or impersonate someone more privileged than themselves.
+// Refusals are audited under the given action name.
+func authorizeAdminTarget(c *fiber.Ctx, principal *dto.Principal, user *models.User, action string) *fiber.Error {
+	role, err := repositories.GetRoleByName(user.Role)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// A role missing from the roles table grants no permissions
+		return nil
+	}
+	if err != nil {
+		return fiber.NewError(http.StatusInternalServerError, "Could not load the user's role")
+	}
+	for _, permission := range role.PermissionNames() {
+		if !principal.HasPermission(permission) {
+			recordAudit(c, models.AuditAdminActionRefused, user.ID, fmt.Sprintf("%s: target holds %s", action, permission))
+			return fiber.NewError(http.StatusForbidden, "You cannot act on a user with permissions you do not hold")
+		}
+	}
+	return nil
+}
+
+// recordAudit logs an administrative action on a user, attributed to the caller.
+// A zero targetUserID records an action on users in general.
+func recordAudit(c *fiber.Ctx, action string, targetUserID uint, details string) {
+	entry := models.AuditLog{
+		Action:     action,
+		TargetType: models.AuditTargetUser,
+		Details:    details,
+		IP:         c.IP(),
+	}
+	if targetUserID != 0 {
+		entry.TargetID = &targetUserID
+	}
+	if principal, err := utils.GetPrincipal(c); err == nil {
+		// An impersonated request is attributed to the administrator behind it
+		actorID := principal.UserID
+		if principal.ActorID != 0 {
+			actorID = principal.ActorID
+		}
+		entry.ActorID = &actorID
+	}
+	if err := repositories.CreateAuditLog(&entry); err != nil {
+		log.Println("Could not record audit entry", action, "for user", targetUserID, err)
+	}
+}
+
+// adminUserResponse builds the administrator view of a user
+func adminUserResponse(user *models.User) dto.AdminUserResponse {
+	response := dto.AdminUserResponse{
+		ID:           user.ID,
+		Username:     user.Username,
+		Email:        user.Email,
+		Role:         user.Role,
+		IsVerified:   user.IsVerified,
+		DisplayName:  user.DisplayName,
+		Timezone:     user.Timezone,
+		Locale:       user.Locale,
+		Status:       user.EffectiveStatus(time.Now()),
+		StatusReason: user.StatusReason,
+		StatusUntil:  user.StatusUntil,
+		CreatedAt:    user.CreatedAt,
+		UpdatedAt:    user.UpdatedAt,
+	}
+	if user.DeletedAt.Valid {
+		response.DeletedAt = &user.DeletedAt.Time
+	}
+	return response
+}
+
+// parseUserFilter reads the user listing filters from the query string
+func parseUserFilter(c *fiber.Ctx) (repositories.UserFilter, error) {
+	filter := repositories.UserFilter{
+		Role:  c.Query("role"),
+		Query: c.Query("q"),
+	}
+
+	var err error
+	if filter.Verified, err = parseBoolQuery(c, "verified"); err != nil {
+		return filter, err
+	}
+	if filter.Status = c.Query("status"); filter.Status != "" && !models.IsValidUserStatus(filter.Status) {
+		return filter, errors.New("status must be active, suspended, locked or pending_deletion")
+	}
+	if filter.CreatedAfter, err = parseTimeQuery(c, "created_after"); err != nil {
+		return filter, err
+	}
+	if filter.CreatedBefore, err = parseTimeQuery(c, "created_before"); err != nil {
+		return filter, err
+	}
+	switch c.Query("deleted", "exclude") {
+	case "exclude":
+		filter.Deleted = repositories.DeletedExclude
+	case "include":
+		filter.Deleted = repositories.DeletedInclude
+	case "only":
+		filter.Deleted = repositories.DeletedOnly
+	default:
+		return filter, errors.New("deleted must be exclude, include or only")
+	}
+	return filter, nil
+}
+
+// parsePagination reads the page and page_size query parameters, clamped to sane bounds
+func parsePagination(c *fiber.Ctx) (int, int) {
+	page := c.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+	pageSize := c.QueryInt("page_size", defaultPageSize)
+	if pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	return page, pageSize
+}
+
+// parseBoolQuery reads an optional boolean query parameter
+func parseBoolQuery(c *fiber.Ctx, key string) (*bool, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be true or false", key)
+	}
+	return &value, nil
+}
+
+// parseTimeQuery reads an optional RFC 3339 timestamp or YYYY-MM-DD date query parameter
+func parseTimeQuery(c *fiber.Ctx, key string) (*time.Time, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if value, err := time.Parse(layout, raw); err == nil {
+			return &value, nil
+		}
+	}
+	return nil, fmt.Errorf("%s must be an RFC 3339 timestamp or YYYY-MM-DD date", key)
+}
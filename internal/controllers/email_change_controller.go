@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wanloq/taskinator/internal/dto"
+	"github.com/wanloq/taskinator/internal/models"
+	"github.com/wanloq/taskinator/internal/repositories"
+	"github.com/wanloq/taskinator/internal/utils"
+)
+
+// emailChangeRevertWindow is how long the old address can undo an email change
+const emailChangeRevertWindow = 7 * 24 * time.Hour
+
+// requestEmailChange records a pending change of the user's email address.
+// The new address receives a confirmation link and the old address a revert link;
+// the user's email and verification state stay untouched until the change is confirmed.
+func requestEmailChange(user *models.User, newEmail string) error {
+	confirmToken, confirmHash, err := utils.GenerateOneTimeToken()
+	if err != nil {
+		return err
+	}
+	revertToken, revertHash, err := utils.GenerateOneTimeToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	change := models.EmailChange{
+		UserID:           user.ID,
+		OldEmail:         user.Email,
+		NewEmail:         newEmail,
+		OldVerified:      user.IsVerified,
+		ConfirmTokenHash: confirmHash,
+		RevertTokenHash:  revertHash,
+		ExpiresAt:        now.Add(utils.OneTimeTokenTTL),
+		RevertExpiresAt:  now.Add(emailChangeRevertWindow),
+	}
+	if err := repositories.CreateEmailChange(&change); err != nil {
+		return err
+	}
+
+	go func() {
+		if err := utils.SendEmailChangeConfirmationEmail(user, change.NewEmail, confirmToken); err != nil {
+			log.Println("Could not send email change confirmation to ", change.NewEmail, err)
+		}
+		if err := utils.SendEmailChangeNoticeEmail(user, change.NewEmail, revertToken, emailChangeRevertWindow); err != nil {
+			log.Println("Could not send email change notice to ", change.OldEmail, err)
+		}
+	}()
+	return nil
+}
+
+// @Summary Confirm email change
+// @Description ConfirmEmailChange applies a pending email change with the token emailed to the new address
+// @Tags Email Verification
+// @Accept json
+// @Produce json
+// @Param request body dto.EmailChangeTokenRequest true "Confirmation Token"
+// @Success 200 {object} map[string]string "Email changed"
+// @Failure 400 {object} map[string]string "Missing token"
+// @Failure 401 {object} map[string]string "Invalid or expired token"
+// @Failure 409 {object} map[string]string "Email already in use"
+// @Router /user/email/change/confirm [post]
+func ConfirmEmailChange(c *fiber.Ctx) error {
+	var req dto.EmailChangeTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := confirmEmailChangeWithToken(req.Token); err != nil {
+		return c.Status(err.Code).JSON(fiber.Map{"error": err.Message})
+	}
+
+	return c.JSON(fiber.Map{"message": "Email address changed and verified."})
+}
+
+// @Summary Revert email change
+// @Description RevertEmailChange cancels or undoes an email change with the token emailed to the previous address
+// @Tags Email Verification
+// @Accept json
+// @Produce json
+// @Param request body dto.EmailChangeTokenRequest true "Revert Token"
+// @Success 200 {object} map[string]string "Email change reverted"
+// @Failure 400 {object} map[string]string "Missing token"
+// @Failure 401 {object} map[string]string "Invalid or expired token"
+// @Failure 409 {object} map[string]string "Email already in use or changed again since"
+// @Router /user/email/change/revert [post]
+func RevertEmailChange(c *fiber.Ctx) error {
+	var req dto.EmailChangeTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	change, err := revertEmailChangeWithToken(req.Token)
+	if err != nil {
+		return c.Status(err.Code).JSON(fiber.Map{"error": err.Message})
+	}
+
+	if change.ConfirmedAt == nil {
+		return c.JSON(fiber.Map{"message": "Email change cancelled."})
+	}
+	return c.JSON(fiber.Map{"message": "Email change reverted. We recommend resetting your password."})
+}
+
+// confirmEmailChangeWithToken redeems a confirmation token and applies its email change
+func confirmEmailChangeWithToken(token string) *fiber.Error {
+	if token == "" {
+		return fiber.NewError(http.StatusBadRequest, "Missing confirmation token")
+	}
+
+	if _, err := repositories.ConfirmEmailChange(utils.HashToken(token)); err != nil {
+		if errors.Is(err, repositories.ErrEmailTaken) {
+			return fiber.NewError(http.StatusConflict, "Email already in use")
+		}
+		return fiber.NewError(http.StatusUnauthorized, "Invalid or expired token")
+	}
+	return nil
+}
+
+// revertEmailChangeWithToken redeems a revert token and cancels or undoes its email change
+func revertEmailChangeWithToken(token string) (*models.EmailChange, *fiber.Error) {
+	if token == "" {
+		return nil, fiber.NewError(http.StatusBadRequest, "Missing revert token")
+	}
+
+	change, err := repositories.RevertEmailChange(utils.HashToken(token))
+	if err != nil {
+		if errors.Is(err, repositories.ErrEmailTaken) {
+			return nil, fiber.NewError(http.StatusConflict, "Email already in use")
+		}
+		if errors.Is(err, repositories.ErrEmailChangedSince) {
+			return nil, fiber.NewError(http.StatusConflict, "The account's email address has changed again since, so this change can no longer be reverted. Contact support to recover the account.")
+		}
+		return nil, fiber.NewError(http.StatusUnauthorized, "Invalid or expired token")
+	}
+	return change, nil
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/wanloq/taskinator/internal/models"
+	"github.com/wanloq/taskinator/internal/repositories"
+	"github.com/wanloq/taskinator/internal/utils"
+)
+
+// Login throttling policy
+const (
+	loginFailureWindow     = time.Hour
+	accountLockoutAttempts = 5
+	ipLockoutAttempts      = 20
+	baseLockoutDuration    = 15 * time.Minute
+	maxLockoutDuration     = 24 * time.Hour
+)
+
+// loginThrottleKey normalizes an email so throttling does not depend on its spelling
+func loginThrottleKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// loginRetryAfter returns how long the caller must wait before another login attempt
+// for this email and IP, or zero if an attempt is allowed now
+func loginRetryAfter(email, ip string) time.Duration {
+	throttles, err := repositories.GetLoginThrottles(loginThrottleKey(email), ip)
+	if err != nil {
+		log.Println("Could not load login throttles:", err)
+		return 0
+	}
+
+	now := time.Now()
+	var wait time.Duration
+	for _, throttle := range throttles {
+		if now.Sub(throttle.LastFailureAt) > loginFailureWindow {
+			continue
+		}
+
+		until := throttle.LastFailureAt.Add(loginBackoff(throttle.Failures))
+		if throttle.LockedUntil != nil && throttle.LockedUntil.After(until) {
+			until = *throttle.LockedUntil
+		}
+		if d := until.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// loginBackoff is the exponential delay imposed after consecutive failures: 1s, 2s, 4s, ...
+func loginBackoff(failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	if failures > 10 {
+		failures = 10
+	}
+	return time.Second << (failures - 1)
+}
+
+// lockoutDuration doubles the lockout for every failure past the threshold
+func lockoutDuration(failures, threshold int) time.Duration {
+	d := baseLockoutDuration
+	for i := threshold; i < failures && d < maxLockoutDuration; i++ {
+		d *= 2
+	}
+	if d > maxLockoutDuration {
+		d = maxLockoutDuration
+	}
+	return d
+}
+
+// recordLoginFailure counts a failed login against the email and IP, locking either once it
+// crosses its threshold. user is nil when the email is unknown; it is only used to notify the owner.
+func recordLoginFailure(email, ip string, user *models.User) {
+	if throttle, err := repositories.RecordLoginFailure(models.ThrottleScopeAccount, loginThrottleKey(email), loginFailureWindow); err != nil {
+		log.Println("Could not record failed login:", err)
+	} else if throttle.Failures >= accountLockoutAttempts {
+		until := time.Now().Add(lockoutDuration(throttle.Failures, accountLockoutAttempts))
+		if err := repositories.LockLoginThrottle(throttle.ID, until); err != nil {
+			log.Println("Could not lock account:", err)
+		} else if user != nil {
+			go func() {
+				if err := utils.SendAccountLockedEmail(user, until); err != nil {
+					log.Println("Could not send lockout mail to ", user.Email, err)
+				}
+			}()
+		}
+	}
+
+	if throttle, err := repositories.RecordLoginFailure(models.ThrottleScopeIP, ip, loginFailureWindow); err != nil {
+		log.Println("Could not record failed login:", err)
+	} else if throttle.Failures >= ipLockoutAttempts {
+		until := time.Now().Add(lockoutDuration(throttle.Failures, ipLockoutAttempts))
+		if err := repositories.LockLoginThrottle(throttle.ID, until); err != nil {
+			log.Println("Could not lock IP:", err)
+		}
+	}
+}
+
+// clearLoginFailures forgets failed logins for an account after a successful login
+func clearLoginFailures(email string) {
+	if err := repositories.ClearLoginThrottle(models.ThrottleScopeAccount, loginThrottleKey(email)); err != nil {
+		log.Println("Could not clear login throttle:", err)
+	}
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wanloq/taskinator/internal/dto"
+	"github.com/wanloq/taskinator/internal/models"
+	"github.com/wanloq/taskinator/internal/repositories"
+	"github.com/wanloq/taskinator/internal/utils"
+)
+
+const (
+	magicLinkCookie  = "magic_login_nonce"
+	magicLinkMessage = "If an account with that email exists, a login link has been sent."
+)
+
+// @Summary Request magic login link
+// @Description RequestMagicLink emails a single-use login link that only works in the requesting browser
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body dto.MagicLinkRequest true "Email to send the link to"
+// @Success 200 {object} map[string]string "Login link sent if the account exists"
+// @Failure 400 {object} map[string]string "Invalid request body"
+// @Router /api/login/magic [post]
+func RequestMagicLink(c *fiber.Ctx) error {
+	var req dto.MagicLinkRequest
+	if err := c.BodyParser(&req); err != nil || req.Email == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	// The nonce cookie binds the link to this browser, whether or not the account exists
+	nonce, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create login link"})
+	}
+	c.Cookie(&fiber.Cookie{
+		Name:     magicLinkCookie,
+		Value:    nonce,
+		Path:     "/api/login/magic",
+		Expires:  time.Now().Add(utils.OneTimeTokenTTL),
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https",
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	// Send in the background so the response time does not depend on whether the account exists
+	go func() {
+		user, err := repositories.GetUserByEmail(req.Email)
+		if err != nil {
+			return
+		}
+
+		loginToken, err := issueBoundOneTimeToken(user.ID, models.TokenPurposeMagicLogin, utils.OneTimeTokenTTL, nonce)
+		if err != nil {
+			log.Println("Could not generate login token", err)
+			return
+		}
+
+		if err := utils.SendMagicLinkEmail(user, loginToken); err != nil {
+			log.Println("Could not send login link to ", user.Email, err)
+		}
+	}()
+
+	return c.JSON(fiber.Map{"message": magicLinkMessage})
+}
+
+// @Summary Log in with magic link
+// @Description VerifyMagicLink exchanges a login link for a JWT. The link must be opened in the browser that requested it.
+// @Tags Authentication
+// @Produce json
+// @Param token query string true "Login token"
+// @Success 200 {object} map[string]string "Token response"
+// @Failure 400 {object} map[string]string "Missing token"
+// @Failure 401 {object} map[string]string "Invalid or expired link"
+// @Router /api/login/magic/verify [get]
+func VerifyMagicLink(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Missing login token"})
+	}
+
+	nonce := c.Cookies(magicLinkCookie)
+	if nonce == "" {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Open the link in the browser you requested it from"})
+	}
+
+	loginToken, err := repositories.ConsumeBoundOneTimeToken(utils.HashToken(token), models.TokenPurposeMagicLogin, utils.HashToken(nonce))
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired login link"})
+	}
+	c.ClearCookie(magicLinkCookie)
+
+	user, err := repositories.GetUserByID(loginToken.UserID)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired login link"})
+	}
+
+	if reason := loginBlockedReason(user); reason != "" {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": reason})
+	}
+
+	// Receiving the link proves ownership of the address
+	if !user.IsVerified {
+		if err := repositories.VerifyUserEmail(user.Email); err != nil {
+			log.Println("Could not mark email verified for ", user.Email, err)
+		}
+	}
+	clearLoginFailures(user.Email)
+	cancelScheduledDeletion(user)
+
+	jwtToken, err := utils.GenerateJWT(user.ID, user.Email, user.Role)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"token": jwtToken})
+}
@@ -0,0 +1,295 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wanloq/taskinator/internal/dto"
+	"github.com/wanloq/taskinator/internal/models"
+	"github.com/wanloq/taskinator/internal/repositories"
+	"github.com/wanloq/taskinator/internal/utils"
+	"gorm.io/gorm"
+)
+
+// @Summary List notifications
+// @Description ListNotifications returns the authenticated user's notifications, newest first, with the unread count
+// @Tags Notifications
+// @Security BearerAuth
+// @Produce json
+// @Param unread query bool false "Only unread notifications"
+// @Param page query int false "Page number, from 1"
+// @Param page_size query int false "Results per page, at most 100"
+// @Success 200 {object} dto.NotificationListResponse "Notifications"
+// @Failure 400 {object} map[string]string "Invalid filter"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /api/notifications [get]
+func ListNotifications(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	unread, err := parseBoolQuery(c, "unread")
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	page, pageSize := parsePagination(c)
+	notifications, total, err := repositories.ListNotifications(principal.UserID, unread != nil && *unread, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not load notifications"})
+	}
+	unreadCount, err := repositories.CountUnreadNotifications(principal.UserID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not load notifications"})
+	}
+
+	return c.JSON(dto.NotificationListResponse{
+		Notifications: notifications,
+		Unread:        unreadCount,
+		Page:          page,
+		PageSize:      pageSize,
+		Total:         total,
+	})
+}
+
+// @Summary Unread notification count
+// @Description GetUnreadNotificationCount returns how many of the authenticated user's notifications are unread
+// @Tags Notifications
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]int "Unread count"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /api/notifications/unread-count [get]
+func GetUnreadNotificationCount(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	count, err := repositories.CountUnreadNotifications(principal.UserID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not load notifications"})
+	}
+	return c.JSON(fiber.Map{"unread": count})
+}
+
+// @Summary Mark notification read
+// @Description MarkNotificationRead marks one of the authenticated user's notifications read
+// @Tags Notifications
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Notification ID"
+// @Success 200 {object} map[string]string "Notification marked read"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 404 {object} map[string]string "Notification not found"
+// @Router /api/notifications/{id}/read [post]
+func MarkNotificationRead(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	if err := repositories.MarkNotificationRead(principal.UserID, uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Notification not found"})
+		}
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update notification"})
+	}
+	return c.JSON(fiber.Map{"message": "Notification marked as read"})
+}
+
+// @Summary Mark all notifications read
+// @Description MarkAllNotificationsRead marks every unread notification of the authenticated user read
+// @Tags Notifications
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]int "Number of notifications marked read"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /api/notifications/read-all [post]
+func MarkAllNotificationsRead(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	updated, err := repositories.MarkAllNotificationsRead(principal.UserID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update notifications"})
+	}
+	return c.JSON(fiber.Map{"marked_read": updated})
+}
+
+// @Summary Get notification preferences
+// @Description GetNotificationPreferences returns the channels each event type is delivered on
+// @Tags Notifications
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} dto.NotificationPreferenceSetting "Preferences"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /api/notifications/preferences [get]
+func GetNotificationPreferences(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	return respondNotificationPreferences(c, principal.UserID)
+}
+
+// @Summary Update notification preferences
+// @Description UpdateNotificationPreferences sets the channels (in_app, email) for the listed event types
+// @Description (assignment, mention, due_reminder, security). An empty channel list turns an event type off.
+// @Description Security alerts are always emailed.
+// @Tags Notifications
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body dto.UpdateNotificationPreferencesRequest true "Preferences to change"
+// @Success 200 {array} dto.NotificationPreferenceSetting "Updated preferences"
+// @Failure 400 {object} map[string]string "Invalid preference"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /api/notifications/preferences [put]
+func UpdateNotificationPreferences(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var req dto.UpdateNotificationPreferencesRequest
+	if err := c.BodyParser(&req); err != nil || len(req.Preferences) == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	preferences := make([]models.NotificationPreference, 0, len(req.Preferences))
+	seen := map[string]bool{}
+	for _, setting := range req.Preferences {
+		if !models.IsValidNotificationType(setting.EventType) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Unknown event type: " + setting.EventType})
+		}
+		if seen[setting.EventType] {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Duplicate event type: " + setting.EventType})
+		}
+		seen[setting.EventType] = true
+
+		preference := models.NotificationPreference{UserID: principal.UserID, EventType: setting.EventType}
+		for _, channel := range setting.Channels {
+			switch channel {
+			case models.ChannelInApp:
+				preference.InApp = true
+			case models.ChannelEmail:
+				preference.Email = true
+			default:
+				return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Unknown channel: " + channel})
+			}
+		}
+		if models.EmailRequired(setting.EventType) && !preference.Email {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Security alerts are always emailed"})
+		}
+		preferences = append(preferences, preference)
+	}
+
+	if err := repositories.SaveNotificationPreferences(preferences); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not save preferences"})
+	}
+	return respondNotificationPreferences(c, principal.UserID)
+}
+
+// @Summary Get digest settings
+// @Description GetDigestSettings returns when the authenticated user's digest email is sent
+// @Tags Notifications
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} dto.DigestSettings "Digest settings"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /api/notifications/digest [get]
+func GetDigestSettings(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	user, err := repositories.GetUserByID(principal.UserID)
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+	return c.JSON(digestSettings(user))
+}
+
+// @Summary Update digest settings
+// @Description UpdateDigestSettings sets how often (off, daily, weekly) and at which local hour the digest
+// @Description of recent notifications is emailed. The hour is in the timezone of the user's profile.
+// @Description While a digest is enabled it replaces the per-event emails for events shown in-app; security alerts are still emailed at once.
+// @Tags Notifications
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body dto.DigestSettings true "Digest settings"
+// @Success 200 {object} dto.DigestSettings "Updated settings"
+// @Failure 400 {object} map[string]string "Invalid settings"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /api/notifications/digest [put]
+func UpdateDigestSettings(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var req dto.DigestSettings
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if !models.IsValidDigestFrequency(req.Frequency) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Frequency must be off, daily or weekly"})
+	}
+	if req.Hour < 0 || req.Hour > 23 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Hour must be between 0 and 23"})
+	}
+	if req.Weekday < 0 || req.Weekday > 6 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Weekday must be between 0 (Sunday) and 6"})
+	}
+
+	fields := map[string]interface{}{
+		"digest_frequency": req.Frequency,
+		"digest_hour":      req.Hour,
+		"digest_weekday":   req.Weekday,
+	}
+	if err := repositories.UpdateUserFields(principal.UserID, fields, nil); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not save digest settings"})
+	}
+
+	user, err := repositories.GetUserByID(principal.UserID)
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+	return c.JSON(digestSettings(user))
+}
+
+// digestSettings describes the user's digest schedule
+func digestSettings(user *models.User) dto.DigestSettings {
+	return dto.DigestSettings{
+		Frequency: user.DigestFrequency,
+		Hour:      user.DigestHour,
+		Weekday:   user.DigestWeekday,
+		Timezone:  user.Timezone,
+	}
+}
+
+// respondNotificationPreferences writes the user's preference for every event type
+func respondNotificationPreferences(c *fiber.Ctx, userID uint) error {
+	preferences, err := repositories.GetNotificationPreferences(userID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not load preferences"})
+	}
+
+	settings := make([]dto.NotificationPreferenceSetting, 0, len(preferences))
+	for _, preference := range preferences {
+		settings = append(settings, dto.NotificationPreferenceSetting{
+			EventType:     preference.EventType,
+			Channels:      preference.Channels(),
+			EmailRequired: models.EmailRequired(preference.EventType),
+		})
+	}
+	return c.JSON(settings)
+}
@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/models"
+	"github.com/wanloq/taskinator/internal/repositories"
+	"github.com/wanloq/taskinator/internal/utils"
+)
+
+const oidcStateCookie = "oidc_state"
+
+// @Summary Sign in with an external provider
+// @Description OIDCLogin redirects to the identity provider using the authorization-code flow with PKCE
+// @Tags Authentication
+// @Param provider path string true "Provider name"
+// @Success 302 "Redirect to the identity provider"
+// @Failure 404 {object} map[string]string "Unknown provider"
+// @Failure 502 {object} map[string]string "Provider unavailable"
+// @Router /api/auth/oidc/{provider}/login [get]
+func OIDCLogin(c *fiber.Ctx) error {
+	provider, ok := config.OIDCProviders[c.Params("provider")]
+	if !ok {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Unknown provider"})
+	}
+
+	state, errState := utils.GenerateRandomToken(16)
+	nonce, errNonce := utils.GenerateRandomToken(16)
+	verifier, challenge, errPKCE := utils.GeneratePKCE()
+	if errState != nil || errNonce != nil || errPKCE != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not start login"})
+	}
+
+	authURL, err := utils.OIDCAuthURL(provider, state, nonce, challenge)
+	if err != nil {
+		log.Println("OIDC login failed for provider", provider.Name, err)
+		return c.Status(http.StatusBadGateway).JSON(fiber.Map{"error": "Identity provider unavailable"})
+	}
+
+	stateToken, err := utils.GenerateOIDCStateToken(utils.OIDCState{
+		Provider:     provider.Name,
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+	})
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not start login"})
+	}
+
+	setOIDCStateCookie(c, stateToken, time.Now().Add(10*time.Minute))
+	return c.Redirect(authURL, http.StatusFound)
+}
+
+// setOIDCStateCookie sets or, with an expiry in the past, removes the login state cookie.
+// Browsers only replace a cookie with the same path, so both go through here.
+func setOIDCStateCookie(c *fiber.Ctx, value string, expires time.Time) {
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     "/api/auth/oidc",
+		Expires:  expires,
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https",
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+// @Summary External provider callback
+// @Description OIDCCallback completes the provider login, links or creates the user by verified email and returns a JWT
+// @Tags Authentication
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "Login state"
+// @Success 200 {object} map[string]string "Token response"
+// @Failure 400 {object} map[string]string "Invalid login state"
+// @Failure 401 {object} map[string]string "Login rejected"
+// @Failure 409 {object} map[string]string "An unverified account uses the email"
+// @Router /api/auth/oidc/{provider}/callback [get]
+func OIDCCallback(c *fiber.Ctx) error {
+	provider, ok := config.OIDCProviders[c.Params("provider")]
+	if !ok {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Unknown provider"})
+	}
+
+	// The state cookie is single-use
+	stateToken := c.Cookies(oidcStateCookie)
+	setOIDCStateCookie(c, "", time.Now().Add(-time.Hour))
+
+	if errParam := c.Query("error"); errParam != "" {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Login rejected by provider: " + errParam})
+	}
+
+	state, err := utils.VerifyOIDCStateToken(stateToken)
+	if err != nil || state.Provider != provider.Name || c.Query("state") == "" || c.Query("state") != state.State {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid login state"})
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Missing authorization code"})
+	}
+
+	rawIDToken, err := utils.ExchangeOIDCCode(provider, code, state.CodeVerifier)
+	if err != nil {
+		log.Println("OIDC code exchange failed for provider", provider.Name, err)
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Login failed"})
+	}
+
+	identity, err := utils.VerifyOIDCIDToken(provider, rawIDToken, state.Nonce)
+	if err != nil {
+		log.Println("OIDC ID token rejected for provider", provider.Name, err)
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Login failed"})
+	}
+
+	user, resolveErr := resolveOIDCUser(provider.Name, identity)
+	if resolveErr != nil {
+		return c.Status(resolveErr.Code).JSON(fiber.Map{"error": resolveErr.Message})
+	}
+
+	if reason := loginBlockedReason(user); reason != "" {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": reason})
+	}
+
+	cancelScheduledDeletion(user)
+
+	token, err := utils.GenerateJWT(user.ID, user.Email, user.Role)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"token": token})
+}
+
+// resolveOIDCUser returns the user linked to an external identity, linking an existing account
+// or creating a new one when the provider asserts a verified email
+func resolveOIDCUser(providerName string, identity *utils.OIDCIdentity) (*models.User, *fiber.Error) {
+	if linked, err := repositories.GetUserIdentity(providerName, identity.Subject); err == nil {
+		user, err := repositories.GetUserByID(linked.UserID)
+		if err != nil {
+			return nil, fiber.NewError(http.StatusUnauthorized, "Linked account not found")
+		}
+		return user, nil
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, fiber.NewError(http.StatusUnauthorized, "Provider did not return a verified email")
+	}
+	email := identity.Email
+
+	link := &models.UserIdentity{
+		Provider: providerName,
+		Subject:  identity.Subject,
+		Email:    email,
+	}
+
+	// Link to an existing account with the same email
+	if user, err := repositories.GetUserByEmail(email); err == nil {
+		// Nobody proved ownership of an unverified address, so whoever registered it may not be
+		// its owner; linking would hand them a shared account
+		if !user.IsVerified {
+			return nil, fiber.NewError(http.StatusConflict, "An unverified account already uses this email; verify it before signing in with "+providerName)
+		}
+		if err := repositories.LinkUserIdentity(user.ID, link); err != nil {
+			return nil, fiber.NewError(http.StatusInternalServerError, "Could not link account")
+		}
+		return user, nil
+	}
+
+	// Otherwise create a new verified account without a usable password
+	unusable, err := unusablePasswordHash()
+	if err != nil {
+		return nil, fiber.NewError(http.StatusInternalServerError, "Could not create account")
+	}
+	user := &models.User{
+		Username:     availableUsername(email),
+		Email:        email,
+		PasswordHash: unusable,
+		IsVerified:   true,
+	}
+	if err := repositories.CreateUserWithIdentity(user, link); err != nil {
+		return nil, fiber.NewError(http.StatusInternalServerError, "Could not create account")
+	}
+	return user, nil
+}
+
+// unusablePasswordHash hashes a random secret nobody knows, for accounts that sign in externally
+func unusablePasswordHash() (string, error) {
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", fiber.NewError(http.StatusInternalServerError, "Could not create account")
+	}
+	return utils.HashPassword(secret)
+}
+
+// availableUsername derives an unused username from the local part of an email address
+func availableUsername(email string) string {
+	base := strings.SplitN(email, "@", 2)[0]
+	if base == "" {
+		base = "user"
+	}
+	username := base
+	for repositories.UsernameExists(username) {
+		suffix, err := utils.GenerateRandomToken(3)
+		if err != nil {
+			suffix = time.Now().Format("150405")
+		}
+		username = base + "-" + strings.ToLower(suffix)
+	}
+	return username
+}
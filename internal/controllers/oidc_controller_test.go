@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/utils"
+)
+
+func TestOIDCCallbackRejectsStateMismatch(t *testing.T) {
+	config.JWTSecretKey = []byte("state-secret")
+	config.OIDCProviders = map[string]config.OIDCProvider{
+		"mock":  {Name: "mock", Issuer: "http://127.0.0.1:0", ClientID: "taskinator"},
+		"other": {Name: "other", Issuer: "http://127.0.0.1:0", ClientID: "taskinator"},
+	}
+	stateToken, err := utils.GenerateOIDCStateToken(utils.OIDCState{Provider: "mock", State: "state-1", Nonce: "nonce-1", CodeVerifier: "verifier"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Get("/api/auth/oidc/:provider/callback", OIDCCallback)
+
+	tests := []struct {
+		name   string
+		target string
+		cookie string
+	}{
+		{"state differs from the cookie", "/api/auth/oidc/mock/callback?code=c&state=state-2", stateToken},
+		{"state missing", "/api/auth/oidc/mock/callback?code=c", stateToken},
+		{"cookie missing", "/api/auth/oidc/mock/callback?code=c&state=state-1", ""},
+		{"cookie from another provider", "/api/auth/oidc/other/callback?code=c&state=state-1", stateToken},
+		{"cookie tampered", "/api/auth/oidc/mock/callback?code=c&state=state-1", stateToken + "x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: tt.cookie})
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("status %d, want %d", resp.StatusCode, http.StatusBadRequest)
+			}
+
+			// The single-use cookie is removed from the path it was set on
+			var cleared bool
+			for _, cookie := range resp.Cookies() {
+				if cookie.Name == oidcStateCookie {
+					cleared = cookie.Value == "" && cookie.Path == "/api/auth/oidc" && cookie.Expires.Before(time.Now()) && cookie.HttpOnly
+				}
+			}
+			if !cleared {
+				t.Fatalf("state cookie not cleared on its path: %v", resp.Header.Values(fiber.HeaderSetCookie))
+			}
+		})
+	}
+}
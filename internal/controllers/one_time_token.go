@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/wanloq/taskinator/internal/models"
+	"github.com/wanloq/taskinator/internal/repositories"
+	"github.com/wanloq/taskinator/internal/utils"
+)
+
+// issueOneTimeToken creates a single-use token for a user and returns its plain value for emailing.
+// Any outstanding token the user holds for the same purpose stops working.
+func issueOneTimeToken(userID uint, purpose string, ttl time.Duration) (string, error) {
+	return issueBoundOneTimeToken(userID, purpose, ttl, "")
+}
+
+// issueBoundOneTimeToken is issueOneTimeToken for a token that can only be redeemed
+// together with the given browser secret. An empty binding leaves the token unbound.
+func issueBoundOneTimeToken(userID uint, purpose string, ttl time.Duration, binding string) (string, error) {
+	plainToken, tokenHash, err := utils.GenerateOneTimeToken()
+	if err != nil {
+		return "", err
+	}
+
+	token := models.OneTimeToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if binding != "" {
+		bindingHash := utils.HashToken(binding)
+		token.BindingHash = &bindingHash
+	}
+	if err := repositories.CreateOneTimeToken(&token); err != nil {
+		return "", err
+	}
+	return plainToken, nil
+}
+
+// consumeOneTimeToken redeems a token for the given purpose and returns the user it was issued to
+func consumeOneTimeToken(plainToken, purpose string) (*models.User, error) {
+	token, err := repositories.ConsumeOneTimeToken(utils.HashToken(plainToken), purpose)
+	if err != nil {
+		return nil, err
+	}
+	return repositories.GetUserByID(token.UserID)
+}
+
+// peekOneTimeToken returns the user a still-valid token was issued to without redeeming it,
+// so callers can validate the rest of the request before the token is burned
+func peekOneTimeToken(plainToken, purpose string) (*models.User, error) {
+	token, err := repositories.GetActiveOneTimeToken(utils.HashToken(plainToken), purpose)
+	if err != nil {
+		return nil, err
+	}
+	return repositories.GetUserByID(token.UserID)
+}
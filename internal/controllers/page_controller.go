@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wanloq/taskinator/internal/models"
+	"github.com/wanloq/taskinator/internal/repositories"
+	"github.com/wanloq/taskinator/internal/utils"
+)
+
+// passwordPage describes a landing page where an emailed token is exchanged for a new password
+type passwordPage struct {
+	path      string
+	purpose   string
+	titleKey  string
+	submitKey string
+	doneKey   string
+}
+
+var (
+	resetPasswordPage = passwordPage{
+		path:      utils.ResetPasswordPath,
+		purpose:   models.TokenPurposePasswordReset,
+		titleKey:  "page.reset.title",
+		submitKey: "page.reset.submit",
+		doneKey:   "page.reset.done",
+	}
+	acceptInvitationPage = passwordPage{
+		path:      utils.AcceptInvitationPath,
+		purpose:   models.TokenPurposeInvitation,
+		titleKey:  "page.invitation.title",
+		submitKey: "page.invitation.submit",
+		doneKey:   "page.invitation.done",
+	}
+)
+
+// ResetPasswordPage renders the form opened from a password reset email
+func ResetPasswordPage(c *fiber.Ctx) error {
+	return showPasswordForm(c, resetPasswordPage)
+}
+
+// SubmitResetPasswordPage handles the password reset form
+func SubmitResetPasswordPage(c *fiber.Ctx) error {
+	return submitPasswordForm(c, resetPasswordPage)
+}
+
+// AcceptInvitationPage renders the form opened from an invitation email
+func AcceptInvitationPage(c *fiber.Ctx) error {
+	return showPasswordForm(c, acceptInvitationPage)
+}
+
+// SubmitAcceptInvitationPage handles the invitation form
+func SubmitAcceptInvitationPage(c *fiber.Ctx) error {
+	return submitPasswordForm(c, acceptInvitationPage)
+}
+
+// VerifyEmailPage verifies the email address from a verification link and shows the result
+func VerifyEmailPage(c *fiber.Ctx) error {
+	locale := pageLocale(c)
+	page := utils.PageContent{Locale: locale, Title: utils.Translate(locale, "page.verify.title")}
+
+	alreadyVerified, err := verifyEmailWithToken(c.Query("token"))
+	switch {
+	case err != nil:
+		return renderPageError(c, page, err)
+	case alreadyVerified:
+		page.Message = utils.Translate(locale, "page.verify.already")
+	default:
+		page.Message = utils.Translate(locale, "page.verify.done")
+	}
+	return renderPage(c, http.StatusOK, page)
+}
+
+// ConfirmEmailChangePage asks the new address to confirm an email change. Opening the link only
+// checks the token; the change is applied when the form is submitted, so link scanners cannot apply it.
+func ConfirmEmailChangePage(c *fiber.Ctx) error {
+	locale := pageLocale(c)
+	token := c.Query("token")
+	page := utils.PageContent{Locale: locale, Title: utils.Translate(locale, "page.email_change_confirm.title")}
+
+	change, err := repositories.GetConfirmableEmailChange(utils.HashToken(token))
+	if token == "" || err != nil {
+		return renderPageError(c, page, fiber.NewError(http.StatusUnauthorized))
+	}
+	page.Message = utils.Translate(locale, "page.email_change_confirm.prompt", "email", change.NewEmail)
+	page.Form = confirmForm(locale, utils.ConfirmEmailChangePath, token, "page.email_change_confirm.submit")
+	return renderPage(c, http.StatusOK, page)
+}
+
+// SubmitConfirmEmailChangePage applies the email change confirmed from the form
+func SubmitConfirmEmailChangePage(c *fiber.Ctx) error {
+	locale := pageLocale(c)
+	page := utils.PageContent{Locale: locale, Title: utils.Translate(locale, "page.email_change_confirm.title")}
+
+	if err := confirmEmailChangeWithToken(c.FormValue("token")); err != nil {
+		return renderEmailChangePageError(c, page, err)
+	}
+	page.Message = utils.Translate(locale, "page.email_change_confirm.done")
+	return renderPage(c, http.StatusOK, page)
+}
+
+// RevertEmailChangePage asks the previous address to confirm undoing an email change; like
+// ConfirmEmailChangePage, nothing changes until the form is submitted
+func RevertEmailChangePage(c *fiber.Ctx) error {
+	locale := pageLocale(c)
+	token := c.Query("token")
+	page := utils.PageContent{Locale: locale, Title: utils.Translate(locale, "page.email_change_revert.title")}
+
+	change, err := repositories.GetRevertableEmailChange(utils.HashToken(token))
+	if token == "" || err != nil {
+		return renderPageError(c, page, fiber.NewError(http.StatusUnauthorized))
+	}
+	page.Message = utils.Translate(locale, "page.email_change_revert.prompt", "email", change.OldEmail)
+	page.Form = confirmForm(locale, utils.RevertEmailChangePath, token, "page.email_change_revert.submit")
+	return renderPage(c, http.StatusOK, page)
+}
+
+// SubmitRevertEmailChangePage cancels or undoes the email change from the form
+func SubmitRevertEmailChangePage(c *fiber.Ctx) error {
+	locale := pageLocale(c)
+	page := utils.PageContent{Locale: locale, Title: utils.Translate(locale, "page.email_change_revert.title")}
+
+	change, err := revertEmailChangeWithToken(c.FormValue("token"))
+	if err != nil {
+		return renderEmailChangePageError(c, page, err)
+	}
+	if change.ConfirmedAt == nil {
+		page.Message = utils.Translate(locale, "page.email_change_revert.cancelled")
+	} else {
+		page.Message = utils.Translate(locale, "page.email_change_revert.reverted")
+	}
+	return renderPage(c, http.StatusOK, page)
+}
+
+// renderEmailChangePageError explains an email change that collides with another account
+// or a later change, and otherwise shows the usual invalid-link page
+func renderEmailChangePageError(c *fiber.Ctx, page utils.PageContent, err *fiber.Error) error {
+	if err.Code != http.StatusConflict {
+		return renderPageError(c, page, err)
+	}
+	page.Message, page.Error = utils.Translate(page.Locale, "page.email_change.conflict"), true
+	return renderPage(c, http.StatusConflict, page)
+}
+
+// showPasswordForm renders the new-password form while the token is still valid
+func showPasswordForm(c *fiber.Ctx, form passwordPage) error {
+	locale := pageLocale(c)
+	token := c.Query("token")
+	page := utils.PageContent{Locale: locale, Title: utils.Translate(locale, form.titleKey)}
+
+	if _, err := peekOneTimeToken(token, form.purpose); err != nil {
+		return renderPageError(c, page, fiber.NewError(http.StatusUnauthorized))
+	}
+	page.Form = passwordForm(locale, form, token)
+	return renderPage(c, http.StatusOK, page)
+}
+
+// submitPasswordForm sets the password from the form, showing the form again if it is rejected
+func submitPasswordForm(c *fiber.Ctx, form passwordPage) error {
+	locale := pageLocale(c)
+	token := c.FormValue("token")
+	newPassword := c.FormValue("new_password")
+	page := utils.PageContent{Locale: locale, Title: utils.Translate(locale, form.titleKey)}
+
+	if newPassword != c.FormValue("confirm_password") {
+		page.Message, page.Error = utils.Translate(locale, "page.password_mismatch"), true
+		page.Form = passwordForm(locale, form, token)
+		return renderPage(c, http.StatusBadRequest, page)
+	}
+
+	if err := setPasswordWithToken(token, newPassword, form.purpose); err != nil {
+		if err.Code == http.StatusBadRequest {
+			// A password rejected by the policy leaves the link valid; explain and let the user try again
+			page.Message, page.Error = err.Message, true
+			page.Form = passwordForm(locale, form, token)
+			return renderPage(c, http.StatusBadRequest, page)
+		}
+		return renderPageError(c, page, err)
+	}
+
+	page.Message = utils.Translate(locale, form.doneKey)
+	return renderPage(c, http.StatusOK, page)
+}
+
+// passwordForm builds the form fields for a password page
+func passwordForm(locale string, form passwordPage, token string) *utils.PageForm {
+	return &utils.PageForm{
+		Action:        form.path,
+		Token:         token,
+		PasswordLabel: utils.Translate(locale, "page.form.password"),
+		ConfirmLabel:  utils.Translate(locale, "page.form.confirm"),
+		Submit:        utils.Translate(locale, form.submitKey),
+	}
+}
+
+// confirmForm builds a form that redeems the token with a single button
+func confirmForm(locale, action, token, submitKey string) *utils.PageForm {
+	return &utils.PageForm{Action: action, Token: token, Submit: utils.Translate(locale, submitKey)}
+}
+
+// renderPageError shows an invalid-link or generic error message for a failed token operation
+func renderPageError(c *fiber.Ctx, page utils.PageContent, err *fiber.Error) error {
+	page.Error, page.Form = true, nil
+	if err.Code == http.StatusInternalServerError {
+		page.Message = utils.Translate(page.Locale, "page.error")
+		return renderPage(c, http.StatusInternalServerError, page)
+	}
+	page.Message = utils.Translate(page.Locale, "page.link_invalid")
+	return renderPage(c, http.StatusBadRequest, page)
+}
+
+// renderPage writes a landing page. The URL carries a single-use token, so the page is never
+// cached, framed or allowed to leak it through the Referer header.
+func renderPage(c *fiber.Ctx, status int, page utils.PageContent) error {
+	body, err := utils.RenderPage(page)
+	if err != nil {
+		log.Println("Could not render page:", err)
+		return c.Status(http.StatusInternalServerError).SendString("Internal server error")
+	}
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderReferrerPolicy, "no-referrer")
+	c.Set(fiber.HeaderXFrameOptions, "DENY")
+	c.Set(fiber.HeaderContentSecurityPolicy, "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'; frame-ancestors 'none'")
+	c.Type("html", "utf-8")
+	return c.Status(status).Send(body)
+}
+
+// pageLocale picks the page language from the Accept-Language header
+func pageLocale(c *fiber.Ctx) string {
+	return utils.ResolveLocale(c.AcceptsLanguages(utils.AvailableLocales()...))
+}
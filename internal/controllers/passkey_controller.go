@@ -0,0 +1,325 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gofiber/fiber/v2"
+	"github.com/wanloq/taskinator/internal/models"
+	"github.com/wanloq/taskinator/internal/repositories"
+	"github.com/wanloq/taskinator/internal/utils"
+)
+
+const (
+	passkeySessionCookie  = "webauthn_session"
+	passkeySessionTimeout = 5 * time.Minute
+)
+
+// @Summary Begin passkey registration
+// @Description BeginPasskeyRegistration returns the credential creation options for a new passkey
+// @Tags Passkeys
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Credential creation options"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /user/passkeys/register/begin [post]
+func BeginPasskeyRegistration(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil || principal.IsDelegated() {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	rp, err := utils.WebAuthn()
+	if err != nil {
+		log.Println("WebAuthn is misconfigured:", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Passkeys are unavailable"})
+	}
+
+	user, err := loadWebAuthnUser(principal.UserID)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	options, session, err := rp.BeginRegistration(user,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithExclusions(webauthn.Credentials(user.WebAuthnCredentials()).CredentialDescriptors()),
+	)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not start passkey registration"})
+	}
+
+	if err := startPasskeySession(c, models.WebAuthnPurposeRegistration, &principal.UserID, session); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not start passkey registration"})
+	}
+	return c.JSON(options)
+}
+
+// @Summary Finish passkey registration
+// @Description FinishPasskeyRegistration verifies the authenticator's attestation and stores the passkey
+// @Tags Passkeys
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param name query string false "Passkey name"
+// @Success 201 {object} models.WebAuthnCredential "Passkey registered"
+// @Failure 400 {object} map[string]string "Invalid registration response"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /user/passkeys/register/finish [post]
+func FinishPasskeyRegistration(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil || principal.IsDelegated() {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	rp, err := utils.WebAuthn()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Passkeys are unavailable"})
+	}
+
+	session, ownerID, err := finishPasskeySession(c, models.WebAuthnPurposeRegistration)
+	if err != nil || ownerID == nil || *ownerID != principal.UserID {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Registration session expired or invalid"})
+	}
+
+	user, err := loadWebAuthnUser(principal.UserID)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(c.Body()))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid registration response"})
+	}
+
+	credential, err := rp.CreateCredential(user, *session, parsed)
+	if err != nil {
+		log.Println("Passkey registration rejected:", err)
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid registration response"})
+	}
+
+	name := strings.TrimSpace(c.Query("name"))
+	if name == "" {
+		name = "Passkey"
+	}
+	stored := utils.CredentialToModel(principal.UserID, name, credential)
+	if err := repositories.CreateWebAuthnCredential(&stored); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Could not save passkey"})
+	}
+
+	return c.Status(http.StatusCreated).JSON(stored)
+}
+
+// @Summary List passkeys
+// @Description ListPasskeys returns the authenticated user's registered passkeys
+// @Tags Passkeys
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.WebAuthnCredential "Passkeys"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /user/passkeys [get]
+func ListPasskeys(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	credentials, err := repositories.GetWebAuthnCredentialsByUser(principal.UserID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not load passkeys"})
+	}
+	return c.JSON(credentials)
+}
+
+// @Summary Delete passkey
+// @Description DeletePasskey removes one of the authenticated user's passkeys
+// @Tags Passkeys
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Passkey ID"
+// @Success 200 {object} map[string]string "Passkey deleted"
+// @Failure 404 {object} map[string]string "Passkey not found"
+// @Router /user/passkeys/{id} [delete]
+func DeletePasskey(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil || principal.IsDelegated() {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	deleted, err := repositories.DeleteWebAuthnCredential(principal.UserID, uint(id))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete passkey"})
+	}
+	if deleted == 0 {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Passkey not found"})
+	}
+	return c.JSON(fiber.Map{"message": "Passkey deleted successfully"})
+}
+
+// @Summary Begin passkey login
+// @Description BeginPasskeyLogin returns assertion options for signing in with a discoverable passkey
+// @Tags Authentication
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Credential request options"
+// @Router /api/login/passkey/begin [post]
+func BeginPasskeyLogin(c *fiber.Ctx) error {
+	rp, err := utils.WebAuthn()
+	if err != nil {
+		log.Println("WebAuthn is misconfigured:", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Passkeys are unavailable"})
+	}
+
+	options, session, err := rp.BeginDiscoverableLogin()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not start passkey login"})
+	}
+
+	if err := startPasskeySession(c, models.WebAuthnPurposeLogin, nil, session); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not start passkey login"})
+	}
+	return c.JSON(options)
+}
+
+// @Summary Finish passkey login
+// @Description FinishPasskeyLogin verifies the passkey assertion and returns a JWT.
+// @Description Passkeys whose signature counter went backwards are flagged as possibly cloned and refused.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Success 200 {object} map[string]string "Token response"
+// @Failure 400 {object} map[string]string "Login session expired or invalid"
+// @Failure 401 {object} map[string]string "Passkey rejected"
+// @Router /api/login/passkey/finish [post]
+func FinishPasskeyLogin(c *fiber.Ctx) error {
+	rp, err := utils.WebAuthn()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Passkeys are unavailable"})
+	}
+
+	session, _, err := finishPasskeySession(c, models.WebAuthnPurposeLogin)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Login session expired or invalid"})
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(c.Body()))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid login response"})
+	}
+
+	var owner *utils.WebAuthnUser
+	credential, err := rp.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		userID, err := utils.UserIDFromWebAuthnHandle(userHandle)
+		if err != nil {
+			return nil, err
+		}
+		owner, err = loadWebAuthnUser(userID)
+		return owner, err
+	}, *session, parsed)
+	if err != nil || owner == nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Passkey rejected"})
+	}
+
+	stored, err := repositories.GetWebAuthnCredentialByCredentialID(credential.ID)
+	if err != nil || stored.UserID != owner.User.ID {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Passkey rejected"})
+	}
+
+	// A counter that did not increase means the private key may exist on more than one device
+	if credential.Authenticator.CloneWarning || stored.CloneWarning {
+		if err := repositories.UpdateWebAuthnCredentialUsage(stored.ID, stored.SignCount, stored.Flags, true); err != nil {
+			log.Println("Could not flag cloned passkey:", err)
+		}
+		log.Println("Passkey sign count regression for user", owner.User.ID, "credential", stored.ID)
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Passkey rejected: it may have been cloned. Remove it and register a new one."})
+	}
+
+	if reason := loginBlockedReason(owner.User); reason != "" {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": reason})
+	}
+
+	if err := repositories.UpdateWebAuthnCredentialUsage(stored.ID, credential.Authenticator.SignCount, uint8(credential.Flags.ProtocolValue()), false); err != nil {
+		log.Println("Could not record passkey usage:", err)
+	}
+	clearLoginFailures(owner.User.Email)
+	cancelScheduledDeletion(owner.User)
+
+	token, err := utils.GenerateJWT(owner.User.ID, owner.User.Email, owner.User.Role)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"token": token})
+}
+
+// loadWebAuthnUser loads a user together with their passkeys
+func loadWebAuthnUser(userID uint) (*utils.WebAuthnUser, error) {
+	user, err := repositories.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := repositories.GetWebAuthnCredentialsByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &utils.WebAuthnUser{User: user, Credentials: credentials}, nil
+}
+
+// startPasskeySession stores ceremony state server-side and hands the browser a random key for it
+func startPasskeySession(c *fiber.Ctx, purpose string, userID *uint, session *webauthn.SessionData) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	key, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(passkeySessionTimeout)
+	if err := repositories.CreateWebAuthnSession(&models.WebAuthnSession{
+		SessionKeyHash: utils.HashToken(key),
+		Purpose:        purpose,
+		UserID:         userID,
+		Data:           string(data),
+		ExpiresAt:      expiresAt,
+	}); err != nil {
+		return err
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     passkeySessionCookie,
+		Value:    key,
+		Path:     "/",
+		Expires:  expiresAt,
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https",
+		SameSite: fiber.CookieSameSiteStrictMode,
+	})
+	return nil
+}
+
+// finishPasskeySession redeems the ceremony state referenced by the browser's cookie; it cannot be replayed
+func finishPasskeySession(c *fiber.Ctx, purpose string) (*webauthn.SessionData, *uint, error) {
+	key := c.Cookies(passkeySessionCookie)
+	c.ClearCookie(passkeySessionCookie)
+
+	stored, err := repositories.ConsumeWebAuthnSession(utils.HashToken(key), purpose)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal([]byte(stored.Data), &session); err != nil {
+		return nil, nil, err
+	}
+	return &session, stored.UserID, nil
+}
@@ -0,0 +1,297 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wanloq/taskinator/internal/dto"
+	"github.com/wanloq/taskinator/internal/models"
+	"github.com/wanloq/taskinator/internal/repositories"
+	"github.com/wanloq/taskinator/internal/utils"
+)
+
+// Profile field limits
+const (
+	maxUsernameLength    = 50
+	maxDisplayNameLength = 100
+)
+
+// localePattern accepts BCP 47 style tags such as "en", "pt-BR" or "zh-Hant-TW"
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8}){0,3}$`)
+
+// @Summary Patch user profile
+// @Description PatchUserProfile changes only the profile fields present in the request.
+// @Description Send the ETag from GET /user/profile as If-Match (or its updated_at in the body) to get 412 instead of overwriting a concurrent change.
+// @Tags Update Profile
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param If-Match header string false "ETag of the profile being modified"
+// @Param request body dto.PatchProfileRequest true "Fields to change"
+// @Success 200 {object} dto.ProfileResponse "Updated profile"
+// @Failure 400 {object} map[string]string "Invalid field"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 412 {object} map[string]string "Profile was modified"
+// @Router /user/profile [patch]
+func PatchUserProfile(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var req dto.PatchProfileRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	user, err := repositories.GetUserByID(principal.UserID)
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	unmodifiedSince, err := profilePrecondition(c.Get(fiber.HeaderIfMatch), req.UpdatedAt)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if unmodifiedSince != nil && unmodifiedSince.UnixMicro() != user.UpdatedAt.UnixMicro() {
+		return profileConflict(c, user)
+	}
+
+	fields := map[string]interface{}{}
+	if req.Username != nil {
+		username, usernameErr := normalizeUsername(*req.Username, user.Username)
+		if usernameErr != nil {
+			return c.Status(usernameErr.Code).JSON(fiber.Map{"error": usernameErr.Message})
+		}
+		if username != user.Username {
+			fields["username"] = username
+		}
+	}
+	if req.DisplayName != nil {
+		displayName := strings.TrimSpace(*req.DisplayName)
+		if len([]rune(displayName)) > maxDisplayNameLength {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("display_name must be at most %d characters", maxDisplayNameLength)})
+		}
+		fields["display_name"] = displayName
+	}
+	if req.Timezone != nil {
+		if _, err := time.LoadLocation(*req.Timezone); err != nil || *req.Timezone == "" || *req.Timezone == "Local" {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "timezone must be an IANA time zone such as Europe/Berlin"})
+		}
+		fields["timezone"] = *req.Timezone
+	}
+	if req.Locale != nil {
+		if !localePattern.MatchString(*req.Locale) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "locale must be a language tag such as en or pt-BR"})
+		}
+		fields["locale"] = *req.Locale
+	}
+
+	emailChanged := req.Email != nil && !strings.EqualFold(*req.Email, user.Email)
+	if emailChanged && principal.IsDelegated() {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "Email can only be changed from your own login session"})
+	}
+	if emailChanged {
+		if address, err := mail.ParseAddress(*req.Email); err != nil || address.Address != *req.Email {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "email must be a valid email address"})
+		}
+		if existingUser, err := repositories.GetUserByEmail(*req.Email); err == nil && existingUser.ID != user.ID {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Email already in use"})
+		}
+	}
+
+	if len(fields) > 0 {
+		if err := repositories.UpdateUserFields(user.ID, fields, &user.UpdatedAt); err != nil {
+			if errors.Is(err, repositories.ErrStaleUpdate) {
+				if current, err := repositories.GetUserByID(user.ID); err == nil {
+					return profileConflict(c, current)
+				}
+			}
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update user"})
+		}
+	}
+	if emailChanged {
+		// The address itself only changes once the new one is confirmed
+		if err := requestEmailChange(user, *req.Email); err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not request email change"})
+		}
+	}
+
+	user, err = repositories.GetUserByID(principal.UserID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not load user"})
+	}
+	c.Set(fiber.HeaderETag, profileETag(user))
+	return c.JSON(profileResponse(user))
+}
+
+// @Summary Upload avatar
+// @Description UploadAvatar stores a profile picture; the image is cropped square and resized server-side
+// @Tags Update Profile
+// @Security BearerAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param avatar formData file true "JPEG, PNG, GIF or WebP image"
+// @Success 200 {object} dto.ProfileResponse "Updated profile"
+// @Failure 400 {object} map[string]string "Invalid image"
+// @Failure 413 {object} map[string]string "Image too large"
+// @Router /user/avatar [put]
+func UploadAvatar(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	header, err := c.FormFile("avatar")
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Missing avatar file"})
+	}
+	if header.Size > utils.AvatarMaxUploadBytes {
+		return c.Status(http.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": fmt.Sprintf("avatar must be at most %d MB", utils.AvatarMaxUploadBytes>>20)})
+	}
+	file, err := header.Open()
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Could not read avatar file"})
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, utils.AvatarMaxUploadBytes+1))
+	if err != nil || len(data) > utils.AvatarMaxUploadBytes {
+		return c.Status(http.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": fmt.Sprintf("avatar must be at most %d MB", utils.AvatarMaxUploadBytes>>20)})
+	}
+
+	resized, err := utils.ResizeAvatar(data)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": utils.ErrInvalidAvatar.Error()})
+	}
+
+	if err := repositories.SaveUserAvatar(&models.UserAvatar{
+		UserID:      principal.UserID,
+		ContentType: utils.AvatarContentType,
+		Data:        resized,
+		UpdatedAt:   time.Now(),
+	}); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not save avatar"})
+	}
+
+	user, err := repositories.GetUserByID(principal.UserID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not load user"})
+	}
+	c.Set(fiber.HeaderETag, profileETag(user))
+	return c.JSON(profileResponse(user))
+}
+
+// @Summary Get avatar
+// @Description GetAvatar returns the authenticated user's profile picture
+// @Tags Profile
+// @Security BearerAuth
+// @Produce png
+// @Success 200 {file} binary "Avatar image"
+// @Failure 404 {object} map[string]string "No avatar"
+// @Router /user/avatar [get]
+func GetAvatar(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	avatar, err := repositories.GetUserAvatar(principal.UserID)
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "No avatar"})
+	}
+
+	c.Set(fiber.HeaderContentType, avatar.ContentType)
+	c.Set(fiber.HeaderCacheControl, "private, max-age=300")
+	return c.Send(avatar.Data)
+}
+
+// @Summary Delete avatar
+// @Description DeleteAvatar removes the authenticated user's profile picture
+// @Tags Update Profile
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]string "Avatar deleted"
+// @Failure 404 {object} map[string]string "No avatar"
+// @Router /user/avatar [delete]
+func DeleteAvatar(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	deleted, err := repositories.DeleteUserAvatar(principal.UserID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete avatar"})
+	}
+	if deleted == 0 {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "No avatar"})
+	}
+	return c.JSON(fiber.Map{"message": "Avatar deleted successfully"})
+}
+
+// profileResponse builds the profile representation returned to its owner
+func profileResponse(user *models.User) dto.ProfileResponse {
+	response := dto.ProfileResponse{
+		Username:    user.Username,
+		Email:       user.Email,
+		Role:        user.Role,
+		Status:      user.IsVerified,
+		DisplayName: user.DisplayName,
+		Timezone:    user.Timezone,
+		Locale:      user.Locale,
+		UpdatedAt:   user.UpdatedAt,
+	}
+	response.AccountStatus = user.EffectiveStatus(time.Now())
+	if response.AccountStatus == models.UserStatusPendingDeletion {
+		response.StatusUntil = user.StatusUntil
+	}
+	if user.AvatarUpdatedAt != nil {
+		response.AvatarURL = fmt.Sprintf("/user/avatar?v=%d", user.AvatarUpdatedAt.Unix())
+	}
+	return response
+}
+
+// profileETag identifies a version of the profile by its last modification time
+func profileETag(user *models.User) string {
+	return `"` + strconv.FormatInt(user.UpdatedAt.UnixMicro(), 10) + `"`
+}
+
+// profilePrecondition returns the profile version the client expects to modify, if it sent one
+func profilePrecondition(ifMatch string, updatedAt *time.Time) (*time.Time, error) {
+	ifMatch = strings.TrimSpace(ifMatch)
+	if ifMatch == "" || ifMatch == "*" {
+		return updatedAt, nil
+	}
+	micros, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`), 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid If-Match header")
+	}
+	version := time.UnixMicro(micros)
+	return &version, nil
+}
+
+// profileConflict reports a lost-update conflict along with the current version
+func profileConflict(c *fiber.Ctx, current *models.User) error {
+	c.Set(fiber.HeaderETag, profileETag(current))
+	return c.Status(http.StatusPreconditionFailed).JSON(fiber.Map{"error": "Profile was modified by another request; reload and try again"})
+}
+
+// normalizeUsername trims a requested username and checks its length and, when it differs
+// from the current one, that no other account uses it
+func normalizeUsername(requested, current string) (string, *fiber.Error) {
+	username := strings.TrimSpace(requested)
+	if username == "" || len(username) > maxUsernameLength {
+		return "", fiber.NewError(http.StatusBadRequest, fmt.Sprintf("username must be 1 to %d characters", maxUsernameLength))
+	}
+	if username != current && repositories.UsernameExists(username) {
+		return "", fiber.NewError(http.StatusBadRequest, "Username already in use")
+	}
+	return username, nil
+}
@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wanloq/taskinator/internal/dto"
+	"github.com/wanloq/taskinator/internal/models"
+	"github.com/wanloq/taskinator/internal/repositories"
+	"github.com/wanloq/taskinator/internal/utils"
+)
+
+// resourceExists looks up whether a shareable resource exists, by resource type
+var resourceExists = map[string]func(id uint) (bool, error){
+	models.ResourceTask: repositories.TaskExists,
+}
+
+// @Summary List shares
+// @Description ListResourceShares returns every grant on a task. Only owners may list shares.
+// @Tags Sharing
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Task ID"
+// @Success 200 {array} models.ResourceGrant "Grants"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 403 {object} map[string]string "Access denied"
+// @Router /api/tasks/{id}/shares [get]
+func ListResourceShares(resourceType string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+		}
+
+		grants, err := repositories.GetResourceGrants(resourceType, uint(id))
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not load shares"})
+		}
+		return c.JSON(grants)
+	}
+}
+
+// @Summary Share resource
+// @Description ShareResource grants another user viewer or editor access to a task. Only owners may share.
+// @Tags Sharing
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param request body dto.ShareRequest true "Share request"
+// @Success 200 {object} models.ResourceGrant "Grant saved"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 403 {object} map[string]string "Access denied"
+// @Failure 404 {object} map[string]string "Task or user not found"
+// @Router /api/tasks/{id}/shares [post]
+func ShareResource(resourceType string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal, err := utils.GetPrincipal(c)
+		if err != nil {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+		}
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+		}
+
+		// Task managers pass the owner check without a grant, so a missing task is only caught here
+		exists, ok := resourceExists[resourceType]
+		if !ok {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Resource not found"})
+		}
+		found, err := exists(uint(id))
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not share resource"})
+		}
+		if !found {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Resource not found"})
+		}
+
+		var req dto.ShareRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+		if req.Access != models.AccessViewer && req.Access != models.AccessEditor {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Access must be viewer or editor"})
+		}
+
+		target, err := repositories.GetUserByEmail(req.Email)
+		if err != nil {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+		}
+		if target.ID == principal.UserID {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Cannot share with yourself"})
+		}
+
+		// Never downgrade an owner through the sharing endpoint
+		if existing, err := repositories.GetResourceGrant(resourceType, uint(id), target.ID); err == nil && existing.Access == models.AccessOwner {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "User already owns this resource"})
+		}
+
+		grant := models.ResourceGrant{
+			ResourceType: resourceType,
+			ResourceID:   uint(id),
+			UserID:       target.ID,
+			Access:       req.Access,
+			GrantedBy:    &principal.UserID,
+		}
+		if err := repositories.SaveResourceGrant(&grant); err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not share resource"})
+		}
+
+		if sharer, err := repositories.GetUserByID(principal.UserID); err == nil {
+			if err := utils.SendResourceSharedNotification(target, sharer, resourceType, grant.ResourceID, grant.Access); err != nil {
+				log.Println("Could not notify user", target.ID, "about shared", resourceType, err)
+			}
+		}
+
+		return c.JSON(grant)
+	}
+}
+
+// @Summary Revoke share
+// @Description RevokeResourceShare removes a user's grant on a task. Owner grants cannot be revoked.
+// @Tags Sharing
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param userId path int true "User ID"
+// @Success 200 {object} map[string]string "Share revoked"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 403 {object} map[string]string "Access denied"
+// @Failure 404 {object} map[string]string "Share not found"
+// @Router /api/tasks/{id}/shares/{userId} [delete]
+func RevokeResourceShare(resourceType string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+		}
+		userID, err := c.ParamsInt("userId")
+		if err != nil || userID <= 0 {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
+		}
+
+		grant, err := repositories.GetResourceGrant(resourceType, uint(id), uint(userID))
+		if err != nil {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Share not found"})
+		}
+		if grant.Access == models.AccessOwner {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Cannot revoke the owner's access"})
+		}
+
+		if err := repositories.DeleteResourceGrant(resourceType, uint(id), uint(userID)); err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not revoke share"})
+		}
+
+		return c.JSON(fiber.Map{"message": "Share revoked successfully"})
+	}
+}
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wanloq/taskinator/internal/dto"
+	"github.com/wanloq/taskinator/internal/models"
+	"github.com/wanloq/taskinator/internal/repositories"
+	"github.com/wanloq/taskinator/internal/utils"
+	"gorm.io/gorm"
+)
+
+// @Summary List tasks
+// @Description GetTasks returns every task the caller holds a grant on, or every task for task managers
+// @Tags Tasks
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.Task "Tasks"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "No tasks found"
+// @Router /api/tasks [get]
+func GetTasks(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	// The same rule as middleware.AuthorizeResource, applied to the whole list in one query
+	var tasks []models.Task
+	if principal.HasPermission(models.PermTasksManage) {
+		tasks, err = repositories.GetTasks()
+	} else {
+		tasks, err = repositories.GetTasksForUser(principal.UserID)
+	}
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not load tasks"})
+	}
+	if len(tasks) == 0 {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "No tasks found"})
+	}
+	return c.JSON(tasks)
+}
+
+// @Summary Create task
+// @Description CreateTask creates a task owned by the caller. An open task with a due date appears in the
+// @Description digests of its owners and editors from its due day on.
+// @Tags Tasks
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body dto.TaskRequest true "Task"
+// @Success 201 {object} models.Task "Created task"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /api/tasks [post]
+func CreateTask(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var req dto.TaskRequest
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	task := models.Task{Name: strings.TrimSpace(req.Name), Status: req.Status, DueAt: req.DueAt}
+	if err := repositories.CreateTask(&task, principal.UserID); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create task"})
+	}
+	return c.Status(http.StatusCreated).JSON(task)
+}
+
+// @Summary Finish task
+// @Description FinishTask marks a task done. Editors and owners may finish a task.
+// @Tags Tasks
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Task ID"
+// @Success 200 {object} models.Task "Finished task"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 403 {object} map[string]string "Access denied"
+// @Failure 404 {object} map[string]string "Task not found"
+// @Router /api/tasks/{id} [patch]
+func FinishTask(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	task, err := repositories.FinishTask(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Task not found"})
+		}
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update task"})
+	}
+	return c.JSON(task)
+}
+
+// @Summary Delete task
+// @Description DeleteTask deletes a task and every grant on it. Only owners may delete a task.
+// @Tags Tasks
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Task ID"
+// @Success 200 {object} map[string]string "Task deleted"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 403 {object} map[string]string "Access denied"
+// @Failure 404 {object} map[string]string "Task not found"
+// @Router /api/tasks/{id} [delete]
+func DeleteTask(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	if err := repositories.DeleteTask(uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Task not found"})
+		}
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete task"})
+	}
+	return c.JSON(fiber.Map{"message": "Task deleted successfully"})
+}
@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wanloq/taskinator/internal/dto"
+	"github.com/wanloq/taskinator/internal/models"
+	"github.com/wanloq/taskinator/internal/repositories"
+	"github.com/wanloq/taskinator/internal/utils"
+)
+
+// @Summary Create personal access token
+// @Description CreatePersonalAccessToken issues a scoped token for scripts and CI. The token is only returned once.
+// @Tags Tokens
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body dto.CreateTokenRequest true "Token name, scopes and optional expiry"
+// @Success 201 {object} map[string]interface{} "Token created"
+// @Failure 400 {object} map[string]string "Invalid request body"
+// @Failure 403 {object} map[string]string "Scope not granted to your role"
+// @Router /user/tokens [post]
+func CreatePersonalAccessToken(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	if principal.IsDelegated() {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "Tokens can only be created from your own login session"})
+	}
+
+	var req dto.CreateTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Scopes) == 0 || req.ExpiresInDays < 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Name and at least one scope are required"})
+	}
+
+	// A token can never carry more than its owner's role grants, plus access to their own data
+	for _, scope := range req.Scopes {
+		if !principal.HasPermission(scope) && !models.IsSelfServicePermission(scope) {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "Scope not granted to your role: " + scope})
+		}
+	}
+
+	plainToken, tokenHash, err := utils.GeneratePersonalAccessToken()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not generate token"})
+	}
+
+	token := models.PersonalAccessToken{
+		UserID:    principal.UserID,
+		Name:      req.Name,
+		TokenHash: tokenHash,
+		Scopes:    strings.Join(req.Scopes, ","),
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := repositories.CreatePersonalAccessToken(&token); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not save token"})
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"id":         token.ID,
+		"name":       token.Name,
+		"scopes":     token.ScopeList(),
+		"expires_at": token.ExpiresAt,
+		"token":      plainToken,
+		"message":    "Store this token now; it will not be shown again.",
+	})
+}
+
+// @Summary List personal access tokens
+// @Description ListPersonalAccessTokens returns the authenticated user's tokens without their secret values
+// @Tags Tokens
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} map[string]interface{} "Tokens"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /user/tokens [get]
+func ListPersonalAccessTokens(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	tokens, err := repositories.GetPersonalAccessTokensByUser(principal.UserID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not load tokens"})
+	}
+
+	now := time.Now()
+	result := make([]fiber.Map, 0, len(tokens))
+	for _, token := range tokens {
+		result = append(result, fiber.Map{
+			"id":           token.ID,
+			"name":         token.Name,
+			"scopes":       token.ScopeList(),
+			"created_at":   token.CreatedAt,
+			"expires_at":   token.ExpiresAt,
+			"last_used_at": token.LastUsedAt,
+			"revoked_at":   token.RevokedAt,
+			"active":       token.IsActive(now),
+		})
+	}
+	return c.JSON(result)
+}
+
+// @Summary Revoke personal access token
+// @Description RevokePersonalAccessToken revokes one of the authenticated user's tokens
+// @Tags Tokens
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Token ID"
+// @Success 200 {object} map[string]string "Token revoked"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 403 {object} map[string]string "Not allowed from a delegated session"
+// @Failure 404 {object} map[string]string "Token not found"
+// @Router /user/tokens/{id} [delete]
+func RevokePersonalAccessToken(c *fiber.Ctx) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	if principal.IsDelegated() {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "Tokens can only be revoked from your own login session"})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	revoked, err := repositories.RevokePersonalAccessToken(principal.UserID, uint(id))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not revoke token"})
+	}
+	if revoked == 0 {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Token not found"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Token revoked successfully"})
+}
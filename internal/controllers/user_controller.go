@@ -2,24 +2,35 @@ package controllers
 
 import (
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/wanloq/taskinator/internal/dto"
 	"github.com/wanloq/taskinator/internal/models"
 	"github.com/wanloq/taskinator/internal/repositories"
 	"github.com/wanloq/taskinator/internal/utils"
-	"golang.org/x/crypto/bcrypt"
+)
+
+// Uniform responses for endpoints that must not reveal whether an account exists
+const (
+	registrationMessage      = "Registration received. Please check your email to continue."
+	verificationSentMessage  = "If an account with that email needs verification, a verification link has been sent."
+	passwordResetSentMessage = "If an account with that email exists, a password reset link has been sent."
 )
 
 // @Summary User Registration
 // @Description RegisterUser handles user registration: Creates a new user and returns a success message or an error.
+// @Description The response is the same whether or not the email or username is taken, so it cannot be used to find
+// @Description accounts; the owner of the address is told by email what happened.
 // @Tags Registration
 // @Accept json
 // @Produce json
 // @Param request body dto.RegisterRequest true "User registration request"
-// @Success 201 {object} map[string]string "User successfully registered"
-// @Failure 400 {object} map[string]string "Invalid request body"
+// @Success 202 {object} map[string]string "Registration received"
+// @Failure 400 {object} map[string]string "Invalid request body or password rejected by policy"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /api/register [post]
 func RegisterUser(c *fiber.Ctx) error {
@@ -28,16 +39,31 @@ func RegisterUser(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	// Check if user already exists
-	existingUser, _ := repositories.GetUserByEmail(req.Email)
-	if existingUser != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Email already in use"})
+	// Enforce the password policy; this depends only on the request, not on existing accounts
+	if err := utils.ValidatePassword(req.Password, req.Username, req.Email); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	// Hash the password before saving
+	// Hash the password before anything else so both outcomes below take the same time
 	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not register user"})
+	}
+
+	// Every outcome queues one email before answering with the same response, so neither the body
+	// nor the timing reveals whether the email or username is in use
+	if existingUser, err := repositories.GetUserByEmail(req.Email); err == nil {
+		if err := utils.SendAlreadyRegisteredEmail(existingUser); err != nil {
+			log.Println("Could not send already-registered mail to ", existingUser.Email, err)
+		}
+		return c.Status(http.StatusAccepted).JSON(fiber.Map{"message": registrationMessage})
+	}
+
+	if repositories.UsernameExists(req.Username) {
+		if err := utils.SendUsernameTakenEmail(req.Email, req.Username); err != nil {
+			log.Println("Could not send username-taken mail to ", req.Email, err)
+		}
+		return c.Status(http.StatusAccepted).JSON(fiber.Map{"message": registrationMessage})
 	}
 
 	// user model
@@ -51,20 +77,22 @@ func RegisterUser(c *fiber.Ctx) error {
 
 	// Save user to database
 	if err := repositories.CreateUser(&user); err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not register user"})
 	}
 
 	// Generate verification token
-	verificationToken, err := utils.GenerateEmailVerificationToken(user.Email)
+	verificationToken, err := issueOneTimeToken(user.ID, models.TokenPurposeEmailVerification, utils.OneTimeTokenTTL)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not generate verification token"})
 	}
 
 	// Send verification email
 	log.Println("Sending verification mail to ", user.Email)
-	go utils.SendVerificationEmail(user.Email, verificationToken)
+	if err := utils.SendVerificationEmail(&user, verificationToken); err != nil {
+		log.Println("Could not send verification email to ", user.Email, err)
+	}
 
-	return c.Status(http.StatusCreated).JSON(fiber.Map{"message": "User registered successfully. Please verify your email."})
+	return c.Status(http.StatusAccepted).JSON(fiber.Map{"message": registrationMessage})
 }
 
 // @Summary User Login
@@ -76,6 +104,7 @@ func RegisterUser(c *fiber.Ctx) error {
 // @Success 200 {object} map[string]string "Token response"
 // @Failure 400 {object} map[string]string "Invalid request"
 // @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 429 {object} map[string]string "Too many login attempts"
 // @Router /api/login [post]
 func LoginUser(c *fiber.Ctx) error {
 	var req dto.LoginRequest
@@ -83,16 +112,42 @@ func LoginUser(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	// Fetch user from database
+	// Refuse attempts while this account or IP is backing off or locked out
+	if wait := loginRetryAfter(req.Email, c.IP()); wait > 0 {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return c.Status(http.StatusTooManyRequests).JSON(fiber.Map{"error": "Too many login attempts. Please try again later."})
+	}
+
+	// Fetch user from database and compare hashed password
 	user, err := repositories.GetUserByEmail(req.Email)
 	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		utils.SimulatePasswordCheck(req.Password)
+		recordLoginFailure(req.Email, c.IP(), nil)
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid credentials"})
+	}
+	if !utils.ComparePasswords(user.PasswordHash, req.Password) {
+		recordLoginFailure(req.Email, c.IP(), user)
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid credentials"})
+	}
+	clearLoginFailures(req.Email)
+
+	// Upgrade hashes made with an outdated algorithm or parameters while the password is at hand
+	if utils.PasswordNeedsRehash(user.PasswordHash) {
+		if rehashed, err := utils.HashPassword(req.Password); err == nil {
+			if err := repositories.UpdateUserPassword(user.Email, rehashed); err != nil {
+				log.Println("Could not upgrade password hash for ", user.Email, err)
+			}
+		}
+	}
+
+	if reason := loginBlockedReason(user); reason != "" {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": reason})
 	}
 
 	if !user.IsVerified {
 		go func() {
 			// Generate email verification token
-			verificationToken, err := utils.GenerateEmailVerificationToken(user.Email)
+			verificationToken, err := issueOneTimeToken(user.ID, models.TokenPurposeEmailVerification, utils.OneTimeTokenTTL)
 			if err != nil {
 				log.Println("Could not generate verification token", err)
 				return
@@ -100,7 +155,7 @@ func LoginUser(c *fiber.Ctx) error {
 
 			// Send verification email
 			log.Println("Sending verification mail to ", user.Email)
-			err = utils.SendVerificationEmail(user.Email, verificationToken)
+			err = utils.SendVerificationEmail(user, verificationToken)
 			if err != nil {
 				log.Println("Could not send verification mail to ", user.Email, err)
 				return
@@ -108,10 +163,8 @@ func LoginUser(c *fiber.Ctx) error {
 		}()
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "User email not verified"})
 	}
-	// Compare hashed password
-	if !utils.ComparePasswords(user.PasswordHash, req.Password) {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid credentials"})
-	}
+
+	cancelScheduledDeletion(user)
 
 	// Generate JWT token (we already implemented this)
 	token, err := utils.GenerateJWT(user.ID, user.Email, user.Role)
@@ -127,45 +180,44 @@ func LoginUser(c *fiber.Ctx) error {
 // @Tags Profile
 // @Security BearerAuth
 // @Produce json
-// @Success 200 {object} map[string]string "User profile"
+// @Success 200 {object} dto.ProfileResponse "User profile"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Router /user/profile [get]
 func GetUserProfile(c *fiber.Ctx) error {
-	// Extract user ID from JWT token
-	userID, _, err := utils.ExtractUserFromToken(c)
+	// Get the authenticated user set by JWTMiddleware
+	principal, err := utils.GetPrincipal(c)
 	if err != nil {
 		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
 	}
 
 	// Fetch user from the database
-	user, err := repositories.GetUserByID(userID)
+	user, err := repositories.GetUserByID(principal.UserID)
 	if err != nil {
 		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
 	}
 
-	// Return user details
-	return c.JSON(fiber.Map{
-		"username": user.Username,
-		"email":    user.Email,
-		"role":     user.Role,
-		"status":   user.IsVerified,
-	})
+	// Return user details along with the version to send back as If-Match
+	c.Set(fiber.HeaderETag, profileETag(user))
+	return c.JSON(profileResponse(user))
 }
 
 // @Summary Update user profile
 // @Description UpdateUserProfile updates the authenticated user's profile if JWT is valid
+// @Description A new email address is kept pending until confirmed from that address; the old address can revert it.
+// @Description Changing the password needs current_password unless the session logged in within the last few minutes.
 // @Tags Update Profile
 // @Security BearerAuth
 // @Accept json
 // @Produce json
 // @Param request body dto.UpdateRequest true "Update Request"
 // @Success 200 {object} map[string]string "Profile Updated successfully"
+// @Failure 400 {object} map[string]string "Invalid username, email or password"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 404 {object} map[string]string "User Not Found"
 // @Router /user/update [put]
 func UpdateUserProfile(c *fiber.Ctx) error {
-	// Extract user ID from JWT token
-	userID, _, err := utils.ExtractUserFromToken(c)
+	// Get the authenticated user set by JWTMiddleware
+	principal, err := utils.GetPrincipal(c)
 	if err != nil {
 		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
 	}
@@ -175,67 +227,107 @@ func UpdateUserProfile(c *fiber.Ctx) error {
 	}
 
 	// Fetch the existing user
-	user, err := repositories.GetUserByID(userID)
+	user, err := repositories.GetUserByID(principal.UserID)
 	if err != nil {
 		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
 	}
 
-	// Check if the new email is already in use by another user
-	existingUser, err := repositories.GetUserByEmail(req.Email)
-	if err == nil && existingUser.ID != user.ID {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Email already in use"})
+	// A new email only takes effect once confirmed, so check it is free before asking
+	emailChanged := req.Email != "" && !strings.EqualFold(req.Email, user.Email)
+	if emailChanged {
+		existingUser, err := repositories.GetUserByEmail(req.Email)
+		if err == nil && existingUser.ID != user.ID {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Email already in use"})
+		}
 	}
 
-	// Update user fields
-	user.Username = req.Username
-	user.Email = req.Email
+	username, usernameErr := normalizeUsername(req.Username, user.Username)
+	if usernameErr != nil {
+		return c.Status(usernameErr.Code).JSON(fiber.Map{"error": usernameErr.Message})
+	}
+
+	// Update only the fields this endpoint owns
+	fields := map[string]interface{}{"username": username}
 
-	// If password is provided, hash and update it
+	// Credentials can only be changed from the user's own session
+	if principal.IsDelegated() && (req.Password != "" || emailChanged) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "Email and password can only be changed from your own login session"})
+	}
+
+	// If password is provided, confirm it is the account holder, then validate, hash and update it
 	if req.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err := confirmRecentLogin(principal, user, req.CurrentPassword, "Enter your current password or log in again to change your password"); err != nil {
+			return c.Status(err.Code).JSON(fiber.Map{"error": err.Message})
+		}
+		if err := utils.ValidatePassword(req.Password, username, user.Email); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		hashedPassword, err := utils.HashPassword(req.Password)
 		if err != nil {
 			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Error hashing password"})
 		}
-		user.PasswordHash = string(hashedPassword)
+		fields["password_hash"] = hashedPassword
 	}
 
 	// Save updated user
-	if err := repositories.UpdateUser(user); err != nil {
+	if err := repositories.UpdateUserFields(user.ID, fields, nil); err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update user"})
 	}
 
+	if emailChanged {
+		if err := requestEmailChange(user, req.Email); err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not request email change"})
+		}
+		return c.JSON(fiber.Map{"message": "Profile updated successfully. Follow the link sent to your new email address to complete the change."})
+	}
+
 	return c.JSON(fiber.Map{"message": "Profile updated successfully"})
 }
 
-// @Summary Delete user profile
-// @Description DeleteUserProfile Removes the authenticated user's profile if JWT is valid
+// @Summary Delete user
+// @Description DeleteUserProfile immediately purges the user given by the path ID (admin only)
 // @Tags Delete Profile
 // @Security BearerAuth
 // @Produce json
-// @Success 200 {object} map[string]string "Profile deleted successfully"
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string "User deleted successfully"
+// @Failure 400 {object} map[string]string "Invalid ID"
 // @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "User holds permissions the caller lacks"
 // @Failure 404 {object} map[string]string "User Not Found"
-// // @Router /user/delete [delete]
-// @Router /user/admin/delete-user/:id [delete]
+// @Router /user/admin/delete-user/{id} [delete]
+// @Router /api/admin/users/{id} [delete]
 func DeleteUserProfile(c *fiber.Ctx) error {
-	// Extract user ID from JWT token
-	userID, _, err := utils.ExtractUserFromToken(c)
+	// Get the authenticated user set by JWTMiddleware
+	principal, err := utils.GetPrincipal(c)
 	if err != nil {
 		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
 	}
 
-	// Fetch the existing user
-	user, err := repositories.GetUserByID(userID)
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+	if uint(id) == principal.UserID {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Use DELETE /user/me to delete your own account"})
+	}
+
+	// Fetch the user to delete
+	user, err := repositories.GetUserByID(uint(id))
 	if err != nil {
 		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
 	}
+	if refusal := authorizeAdminTarget(c, principal, user, "delete"); refusal != nil {
+		return c.Status(refusal.Code).JSON(fiber.Map{"error": refusal.Message})
+	}
 
 	// Delete user
-	if err := repositories.DeleteUser(user); err != nil {
+	if err := repositories.PurgeUser(user.ID); err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete user"})
 	}
+	recordAudit(c, models.AuditUserDeleted, user.ID, "")
 
-	return c.JSON(fiber.Map{"message": "Profile deleted successfully"})
+	return c.JSON(fiber.Map{"message": "User deleted successfully"})
 }
 
 // @Summary Request Email Verification
@@ -244,10 +336,8 @@ func DeleteUserProfile(c *fiber.Ctx) error {
 // @Accept json
 // @Produce json
 // @Param request body dto.RequestEmailVerification true "Email verification request"
-// @Success 200 {object} map[string]string "Verification email sent successfully"
+// @Success 200 {object} map[string]string "Verification email sent if the account needs it"
 // @Failure 400 {object} map[string]string "Invalid request body"
-// @Failure 404 {object} map[string]string "User not found"
-// @Failure 500 {object} map[string]string "Internal server error"
 // @Router /user/email/verify/request [post]
 func RequestEmailVerification(c *fiber.Ctx) error {
 	var req dto.RequestEmailVerification
@@ -255,27 +345,28 @@ func RequestEmailVerification(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	// Check if user exists
-	user, err := repositories.GetUserByEmail(req.Email)
-	if err != nil {
-		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
-	}
+	// Send in the background so the response time does not depend on whether the account exists
+	go func() {
+		user, err := repositories.GetUserByEmail(req.Email)
+		if err != nil || user.IsVerified {
+			return
+		}
 
-	// Generate email verification token
-	verificationToken, err := utils.GenerateEmailVerificationToken(user.Email)
-	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not generate verification token"})
-	}
+		// Generate email verification token
+		verificationToken, err := issueOneTimeToken(user.ID, models.TokenPurposeEmailVerification, utils.OneTimeTokenTTL)
+		if err != nil {
+			log.Println("Could not generate verification token", err)
+			return
+		}
 
-	// Send verification email
-	log.Println("Sending verification mail to ", user.Email)
-	err = utils.SendVerificationEmail(user.Email, verificationToken)
-	if err != nil {
-		log.Println("Could not Send verification mail to ", user.Email, err)
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
-	}
+		// Send verification email
+		log.Println("Sending verification mail to ", user.Email)
+		if err := utils.SendVerificationEmail(user, verificationToken); err != nil {
+			log.Println("Could not Send verification mail to ", user.Email, err)
+		}
+	}()
 
-	return c.JSON(fiber.Map{"message": "Verification email sent successfully"})
+	return c.JSON(fiber.Map{"message": verificationSentMessage})
 }
 
 // @Summary Verify Email
@@ -294,27 +385,33 @@ func VerifyEmail(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing verification token"})
 	}
 
-	email, err := utils.VerifyEmailToken(token)
+	alreadyVerified, err := verifyEmailWithToken(token)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired token"})
+		return c.Status(err.Code).JSON(fiber.Map{"error": err.Message})
+	}
+	if alreadyVerified {
+		return c.JSON(fiber.Map{"message": "Email already verified."})
 	}
 
-	// Extract user info
-	user, err := repositories.GetUserByEmail(email)
+	return c.JSON(fiber.Map{"message": "Email successfully verified. You may now log in."})
+}
+
+// verifyEmailWithToken redeems a verification token and marks the user's email verified,
+// reporting whether it already was
+func verifyEmailWithToken(token string) (bool, *fiber.Error) {
+	// Redeem the single-use token
+	user, err := consumeOneTimeToken(token, models.TokenPurposeEmailVerification)
 	if err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		return false, fiber.NewError(http.StatusUnauthorized, "Invalid or expired token")
 	}
 	if user.IsVerified {
-		return c.JSON(fiber.Map{"message": "Email already verified."})
+		return true, nil
 	}
 
-	// Update and Save user
-	user.IsVerified = true
-	if err := repositories.UpdateUser(user); err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update user"})
+	if err := repositories.UpdateUserFields(user.ID, map[string]interface{}{"is_verified": true}, nil); err != nil {
+		return false, fiber.NewError(http.StatusInternalServerError, "Could not update user")
 	}
-
-	return c.JSON(fiber.Map{"message": "Email successfully verified. You may now log in."})
+	return false, nil
 }
 
 // @Summary Request Password Reset
@@ -323,10 +420,8 @@ func VerifyEmail(c *fiber.Ctx) error {
 // @Accept json
 // @Produce json
 // @Param request body dto.PasswordResetRequest true "User email for password reset"
-// @Success 200 {object} map[string]string "Password reset link sent"
+// @Success 200 {object} map[string]string "Password reset link sent if the account exists"
 // @Failure 400 {object} map[string]string "Invalid request"
-// @Failure 404 {object} map[string]string "User not found"
-// @Failure 500 {object} map[string]string "Internal server error"
 // @Router /user/password-reset/request [post]
 func RequestPasswordReset(c *fiber.Ctx) error {
 	var req dto.PasswordResetRequest
@@ -334,21 +429,27 @@ func RequestPasswordReset(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	user, err := repositories.GetUserByEmail(req.Email)
-	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
-	}
+	// Send in the background so the response time does not depend on whether the account exists
+	go func() {
+		user, err := repositories.GetUserByEmail(req.Email)
+		if err != nil {
+			return
+		}
 
-	// Generate password reset token
-	resetToken, err := utils.GeneratePasswordResetToken(user.Email)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not generate reset token"})
-	}
+		// Generate password reset token
+		resetToken, err := issueOneTimeToken(user.ID, models.TokenPurposePasswordReset, utils.OneTimeTokenTTL)
+		if err != nil {
+			log.Println("Could not generate reset token", err)
+			return
+		}
 
-	// Send email with password reset link
-	go utils.SendPasswordResetEmail(user.Email, resetToken)
+		// Send email with password reset link
+		if err := utils.SendPasswordResetEmail(user, resetToken); err != nil {
+			log.Println("Could not send password reset mail to ", user.Email, err)
+		}
+	}()
 
-	return c.JSON(fiber.Map{"message": "Password reset link sent to your email."})
+	return c.JSON(fiber.Map{"message": passwordResetSentMessage})
 }
 
 // @Summary Reset User Password
@@ -368,20 +469,44 @@ func PasswordReset(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	email, err := utils.VerifyPasswordResetToken(req.Token)
+	if err := setPasswordWithToken(req.Token, req.NewPassword, models.TokenPurposePasswordReset); err != nil {
+		return c.Status(err.Code).JSON(fiber.Map{"error": err.Message})
+	}
+
+	return c.JSON(fiber.Map{"message": "Password successfully reset."})
+}
+
+// setPasswordWithToken redeems a password reset or invitation token and sets the new password.
+// The password is validated and hashed before the token is redeemed so a rejected password does
+// not burn the link. Accepting an invitation also verifies the email, since the link reached the address.
+func setPasswordWithToken(token, newPassword, purpose string) *fiber.Error {
+	account, err := peekOneTimeToken(token, purpose)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired token"})
+		return fiber.NewError(http.StatusUnauthorized, "Invalid or expired token")
+	}
+	if err := utils.ValidatePassword(newPassword, account.Username, account.Email); err != nil {
+		return fiber.NewError(http.StatusBadRequest, err.Error())
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	hashedPassword, err := utils.HashPassword(newPassword)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not hash password"})
+		return fiber.NewError(http.StatusInternalServerError, "Could not hash password")
 	}
 
-	err = repositories.UpdateUserPassword(email, string(hashedPassword))
+	// Redeem the single-use token
+	user, err := consumeOneTimeToken(token, purpose)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update password"})
+		return fiber.NewError(http.StatusUnauthorized, "Invalid or expired token")
 	}
 
-	return c.JSON(fiber.Map{"message": "Password successfully reset."})
+	if purpose == models.TokenPurposeInvitation {
+		if err := repositories.UpdateUserFields(user.ID, map[string]interface{}{"password_hash": hashedPassword, "is_verified": true}, nil); err != nil {
+			return fiber.NewError(http.StatusInternalServerError, "Could not activate account")
+		}
+		return nil
+	}
+	if err := repositories.UpdateUserPassword(user.Email, hashedPassword); err != nil {
+		return fiber.NewError(http.StatusInternalServerError, "Could not update password")
+	}
+	return nil
 }
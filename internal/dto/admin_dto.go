@@ -0,0 +1,98 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/wanloq/taskinator/internal/models"
+)
+
+type CreateRoleRequest struct {
+	Name        string   `json:"name" validate:"required"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type UpdateRolePermissionsRequest struct {
+	Permissions []string `json:"permissions" validate:"required"`
+}
+
+type AssignRoleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
+
+// SuspendUserRequest explains a suspension or lock; without Until it lasts until the user is reinstated
+type SuspendUserRequest struct {
+	Reason string     `json:"reason"`
+	Until  *time.Time `json:"until,omitempty"`
+}
+
+// AdminUserResponse is a user as seen by administrators
+type AdminUserResponse struct {
+	ID           uint       `json:"id"`
+	Username     string     `json:"username"`
+	Email        string     `json:"email"`
+	Role         string     `json:"role"`
+	IsVerified   bool       `json:"is_verified"`
+	DisplayName  string     `json:"display_name"`
+	Timezone     string     `json:"timezone"`
+	Locale       string     `json:"locale"`
+	Status       string     `json:"status"`
+	StatusReason string     `json:"status_reason,omitempty"`
+	StatusUntil  *time.Time `json:"status_until"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	DeletedAt    *time.Time `json:"deleted_at"`
+}
+
+// UserListResponse is one page of an administrator user listing
+type UserListResponse struct {
+	Users    []AdminUserResponse `json:"users"`
+	Page     int                 `json:"page"`
+	PageSize int                 `json:"page_size"`
+	Total    int64               `json:"total"`
+}
+
+// AuditLogListResponse is one page of the audit log
+type AuditLogListResponse struct {
+	Entries  []models.AuditLog `json:"entries"`
+	Page     int               `json:"page"`
+	PageSize int               `json:"page_size"`
+	Total    int64             `json:"total"`
+}
+
+// EmailOutboxListResponse is one page of the email outbox
+type EmailOutboxListResponse struct {
+	Messages []models.EmailOutbox `json:"messages"`
+	Page     int                  `json:"page"`
+	PageSize int                  `json:"page_size"`
+	Total    int64                `json:"total"`
+}
+
+// ImportUserRow is one user in a bulk import; role defaults to "user"
+type ImportUserRow struct {
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	Role        string `json:"role,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+}
+
+// ImportRowResult reports what happened to one row of a bulk import
+type ImportRowResult struct {
+	// Row is 1-based and counts data rows, not the CSV header
+	Row      int      `json:"row"`
+	Username string   `json:"username"`
+	Email    string   `json:"email"`
+	Status   string   `json:"status"`
+	Errors   []string `json:"errors,omitempty"`
+	UserID   uint     `json:"user_id,omitempty"`
+}
+
+// ImportUsersResponse summarises a bulk import
+type ImportUsersResponse struct {
+	DryRun  bool              `json:"dry_run"`
+	Total   int               `json:"total"`
+	Valid   int               `json:"valid"`
+	Created int               `json:"created"`
+	Failed  int               `json:"failed"`
+	Rows    []ImportRowResult `json:"rows"`
+}
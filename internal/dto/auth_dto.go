@@ -1,11 +1,16 @@
 package dto
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/wanloq/taskinator/internal/models"
+)
 
 type RegisterRequest struct {
 	Username string `json:"username" validate:"required"`
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Password string `json:"password" validate:"required"`
 }
 
 type LoginRequest struct {
@@ -22,15 +27,53 @@ type UpdateRequest struct {
 	Username string `json:"username" validate:"required"`
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password,omitempty"`
+	// CurrentPassword is required to change the password unless the session logged in within the re-authentication window
+	CurrentPassword string `json:"current_password,omitempty"`
 }
 
 type Claims struct {
 	UserID uint   `json:"user_id"`
 	Email  string `json:"email"`
 	Role   string `json:"role"`
+	// ActorID is the administrator acting as UserID in an impersonation token
+	ActorID uint `json:"actor_id,omitempty"`
+	// ReadOnly impersonation tokens may not be used for write requests
+	ReadOnly bool `json:"read_only,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Principal is the authenticated caller attached to the request context by JWTMiddleware
+type Principal struct {
+	UserID      uint
+	Email       string
+	Role        string
+	Permissions []string
+	// TokenID is set when the request was authenticated with a personal access token
+	TokenID uint
+	// ActorID is set when an administrator is impersonating UserID
+	ActorID uint
+	// ReadOnly is set for impersonation sessions that may not modify anything
+	ReadOnly bool
+	// AuthenticatedAt is when the session's login happened; it is zero for access tokens
+	AuthenticatedAt time.Time
+}
+
+// IsDelegated reports whether the request acts for the user without being their own session.
+// Delegated callers, such as access tokens and impersonators, may not manage the user's credentials.
+func (p *Principal) IsDelegated() bool {
+	return p.TokenID != 0 || p.ActorID != 0
+}
+
+// HasPermission reports whether the principal's role grants the permission
+func (p *Principal) HasPermission(permission string) bool {
+	for _, granted := range p.Permissions {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
 type RequestEmailVerification struct {
 	Email string `json:"email" validate:"required,email"`
 }
@@ -41,5 +84,71 @@ type PasswordResetRequest struct {
 
 type ResetRequest struct {
 	Token       string `json:"token" validate:"required"`
-	NewPassword string `json:"new_password" validate:"required,min=6"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+type EmailChangeTokenRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type CreateTokenRequest struct {
+	Name          string   `json:"name" validate:"required"`
+	Scopes        []string `json:"scopes" validate:"required"`
+	ExpiresInDays int      `json:"expires_in_days,omitempty"`
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// PatchProfileRequest holds a partial profile update; only non-nil fields are changed
+type PatchProfileRequest struct {
+	Username    *string `json:"username,omitempty"`
+	Email       *string `json:"email,omitempty"`
+	DisplayName *string `json:"display_name,omitempty"`
+	Timezone    *string `json:"timezone,omitempty"`
+	Locale      *string `json:"locale,omitempty"`
+	// UpdatedAt may be sent instead of an If-Match header to guard against lost updates
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// ProfileResponse is the authenticated user's profile
+type ProfileResponse struct {
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	Role        string `json:"role"`
+	Status      bool   `json:"status"`
+	DisplayName string `json:"display_name"`
+	Timezone    string `json:"timezone"`
+	Locale      string `json:"locale"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
+	// AccountStatus is "active" or "pending_deletion" for users who can see their profile
+	AccountStatus string     `json:"account_status"`
+	StatusUntil   *time.Time `json:"status_until,omitempty"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+type DeleteAccountRequest struct {
+	// Password is required unless the session logged in within the re-authentication window
+	Password string `json:"password"`
+}
+
+// AccountExport is everything stored about a user, returned by the data export endpoint
+type AccountExport struct {
+	ExportedAt     time.Time                    `json:"exported_at"`
+	Profile        ProfileResponse              `json:"profile"`
+	CreatedAt      time.Time                    `json:"created_at"`
+	AccountStatus  string                       `json:"account_status"`
+	StatusUntil    *time.Time                   `json:"status_until,omitempty"`
+	Identities     []models.UserIdentity        `json:"linked_identities"`
+	Passkeys       []models.WebAuthnCredential  `json:"passkeys"`
+	AccessTokens   []models.PersonalAccessToken `json:"access_tokens"`
+	ResourceGrants []models.ResourceGrant       `json:"resource_grants"`
+}
+
+type ImpersonateRequest struct {
+	// Minutes the token stays valid; defaults to 15 and is capped at 60
+	Minutes int `json:"minutes"`
+	// AllowWrites lets the token be used for write requests; by default it is read-only
+	AllowWrites bool `json:"allow_writes"`
 }
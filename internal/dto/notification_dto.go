@@ -0,0 +1,36 @@
+package dto
+
+import "github.com/wanloq/taskinator/internal/models"
+
+// NotificationListResponse is one page of the user's notifications
+type NotificationListResponse struct {
+	Notifications []models.Notification `json:"notifications"`
+	Unread        int64                 `json:"unread"`
+	Page          int                   `json:"page"`
+	PageSize      int                   `json:"page_size"`
+	Total         int64                 `json:"total"`
+}
+
+// NotificationPreferenceSetting lists the channels one event type is delivered on; an empty list turns it off
+type NotificationPreferenceSetting struct {
+	EventType string   `json:"event_type"`
+	Channels  []string `json:"channels"`
+	// EmailRequired marks event types that are always emailed
+	EmailRequired bool `json:"email_required,omitempty"`
+}
+
+// UpdateNotificationPreferencesRequest changes the listed event types and leaves the others as they are
+type UpdateNotificationPreferencesRequest struct {
+	Preferences []NotificationPreferenceSetting `json:"preferences"`
+}
+
+// DigestSettings schedules the digest email in the user's timezone
+type DigestSettings struct {
+	// Frequency is off, daily or weekly
+	Frequency string `json:"frequency"`
+	// Hour is the local hour, 0-23, from which the digest is sent
+	Hour int `json:"hour"`
+	// Weekday is the day weekly digests are sent, 0 (Sunday) to 6
+	Weekday  int    `json:"weekday"`
+	Timezone string `json:"timezone,omitempty"`
+}
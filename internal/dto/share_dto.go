@@ -0,0 +1,6 @@
+package dto
+
+type ShareRequest struct {
+	Email  string `json:"email" validate:"required,email"`
+	Access string `json:"access" validate:"required,oneof=viewer editor"`
+}
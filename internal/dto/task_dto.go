@@ -0,0 +1,9 @@
+package dto
+
+import "time"
+
+type TaskRequest struct {
+	Name   string     `json:"name" validate:"required"`
+	Status bool       `json:"status"`
+	DueAt  *time.Time `json:"due_at"`
+}
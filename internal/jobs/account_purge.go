@@ -0,0 +1,46 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/wanloq/taskinator/internal/repositories"
+)
+
+// accountPurgeBatchSize bounds how many accounts one purge run handles
+const accountPurgeBatchSize = 100
+
+// StartAccountPurge periodically purges accounts whose deletion grace period has ended.
+// It runs once immediately and then every interval until the process exits.
+func StartAccountPurge(interval time.Duration) {
+	go func() {
+		for {
+			PurgeDueAccounts(time.Now())
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// PurgeDueAccounts purges every account scheduled for deletion at or before now
+func PurgeDueAccounts(now time.Time) {
+	for {
+		users, err := repositories.GetUsersDueForDeletion(now, accountPurgeBatchSize)
+		if err != nil {
+			log.Println("Could not load accounts due for deletion:", err)
+			return
+		}
+
+		purged := 0
+		for _, user := range users {
+			if err := repositories.PurgeUser(user.ID); err != nil {
+				log.Println("Could not purge account", user.ID, err)
+				continue
+			}
+			purged++
+			log.Println("Purged account", user.ID, "after its deletion grace period")
+		}
+		if len(users) < accountPurgeBatchSize || purged == 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/wanloq/taskinator/internal/models"
+	"github.com/wanloq/taskinator/internal/repositories"
+	"github.com/wanloq/taskinator/internal/utils"
+)
+
+// digestBatchSize bounds how many subscribers one query loads
+const digestBatchSize = 200
+
+// StartDigestJob sends digest emails that have come due, checking every interval. Each user's
+// digest is claimed in the database before it is sent, so restarts and several replicas running
+// the job never send one twice.
+func StartDigestJob(interval time.Duration) {
+	go func() {
+		for {
+			SendDueDigests(time.Now())
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// SendDueDigests sends every digest due at now
+func SendDueDigests(now time.Time) {
+	var afterID uint
+	for {
+		users, err := repositories.GetDigestSubscribers(afterID, digestBatchSize)
+		if err != nil {
+			log.Println("Could not load digest subscribers:", err)
+			return
+		}
+		for i := range users {
+			sendDigest(&users[i], now)
+		}
+		if len(users) < digestBatchSize {
+			return
+		}
+		afterID = users[len(users)-1].ID
+	}
+}
+
+// sendDigest sends one user's digest if it is due and nobody has handled it yet
+func sendDigest(user *models.User, now time.Time) {
+	if user.EffectiveStatus(now) != models.UserStatusActive {
+		return
+	}
+	location, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	local := now.In(location)
+	if !user.DigestDueOn(local) {
+		return
+	}
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+
+	claimed, err := repositories.ClaimDigest(user.ID, day)
+	if err != nil || !claimed {
+		if err != nil {
+			log.Println("Could not claim digest for user", user.ID, err)
+		}
+		return
+	}
+
+	since := now.Add(-user.DigestPeriod())
+	if previous, err := repositories.GetPreviousDigestAt(user.ID, day); err != nil {
+		log.Println("Could not load previous digest for user", user.ID, err)
+	} else if previous != nil && previous.After(since) {
+		since = *previous
+	}
+
+	// Open tasks due before the end of the user's day: overdue ones and those due today
+	endOfDay := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, location)
+	dueTasks, err := repositories.GetOpenTasksDueBefore(user.ID, endOfDay, utils.DigestMaxItems)
+	if err != nil {
+		log.Println("Could not load due tasks for digest of user", user.ID, err)
+		releaseDigest(user.ID, day)
+		return
+	}
+
+	notifications, err := repositories.GetNotificationsSince(user.ID, since, utils.DigestMaxItems+1)
+	if err != nil {
+		log.Println("Could not load notifications for digest of user", user.ID, err)
+		releaseDigest(user.ID, day)
+		return
+	}
+	// Nothing to report: the claim stays so the day is not checked again
+	if len(dueTasks) == 0 && len(notifications) == 0 {
+		return
+	}
+
+	more := 0
+	if len(notifications) > utils.DigestMaxItems {
+		total, err := repositories.CountNotificationsSince(user.ID, since)
+		if err != nil {
+			total = int64(len(notifications))
+		}
+		notifications = notifications[:utils.DigestMaxItems]
+		more = int(total) - utils.DigestMaxItems
+	}
+	if err := utils.SendDigestEmail(user, since, now, dueTasks, notifications, more); err != nil {
+		log.Println("Could not send digest to user", user.ID, err)
+		releaseDigest(user.ID, day)
+		return
+	}
+	if err := repositories.CompleteDigest(user.ID, day, len(dueTasks)+len(notifications)+more); err != nil {
+		log.Println("Could not record digest for user", user.ID, err)
+	}
+}
+
+// releaseDigest gives up a claim so the next run tries the digest again
+func releaseDigest(userID uint, day time.Time) {
+	if err := repositories.ReleaseDigest(userID, day); err != nil {
+		log.Println("Could not release digest claim for user", userID, err)
+	}
+}
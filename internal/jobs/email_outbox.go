@@ -0,0 +1,133 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/models"
+	"github.com/wanloq/taskinator/internal/repositories"
+	"github.com/wanloq/taskinator/internal/utils"
+	"gorm.io/gorm"
+)
+
+// Outbox delivery timing
+const (
+	// emailLockDuration is how long a claimed message is reserved for its worker before another may retry it
+	emailLockDuration = 5 * time.Minute
+	// emailBaseBackoff is the delay after the first failed attempt; it doubles with every further failure
+	emailBaseBackoff = 30 * time.Second
+	// emailMaxBackoff caps the delay between attempts
+	emailMaxBackoff = time.Hour
+	// emailCleanupInterval is how often old delivery records are removed
+	emailCleanupInterval = time.Hour
+	// maxLastErrorLength bounds the error stored with a failed message
+	maxLastErrorLength = 1000
+	// linkExpiredError is recorded for messages dropped because their link expired in the queue
+	linkExpiredError = "link expired before delivery"
+)
+
+// StartEmailWorkers starts n workers that deliver queued email, each polling the outbox every
+// poll interval while it is empty, plus a cleaner for old delivery records
+func StartEmailWorkers(n int, poll time.Duration) {
+	for i := 0; i < n; i++ {
+		go func() {
+			for {
+				if !DeliverNextEmail() {
+					time.Sleep(poll)
+				}
+			}
+		}()
+	}
+
+	go func() {
+		for {
+			cutoff := time.Now().Add(-config.Mail.SentRetention)
+			if err := repositories.DeleteSentEmailsBefore(cutoff); err != nil {
+				log.Println("Could not clean up sent email:", err)
+			}
+			if err := repositories.DeleteDeadEmailsBefore(cutoff); err != nil {
+				log.Println("Could not clean up dead-lettered email:", err)
+			}
+			if err := repositories.ClearExpiredDeadEmailPayloads(); err != nil {
+				log.Println("Could not clear expired dead-lettered email:", err)
+			}
+			time.Sleep(emailCleanupInterval)
+		}
+	}()
+}
+
+// DeliverNextEmail claims and attempts one due message. It reports whether a message was
+// claimed, so workers keep draining the queue without waiting.
+func DeliverNextEmail() bool {
+	message, err := repositories.ClaimEmail(emailLockDuration)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("Could not claim queued email:", err)
+		}
+		return false
+	}
+
+	if message.ExpiresAt != nil && !time.Now().Before(*message.ExpiresAt) {
+		// Delivering a dead link only confuses the recipient; they can request a new one
+		log.Println("Email", message.ID, "to", message.Recipient, "dead-lettered: its link expired before delivery")
+		if err := repositories.MarkEmailDead(message.ID, linkExpiredError); err != nil {
+			log.Println("Could not record delivery result for email", message.ID, err)
+		}
+		return true
+	}
+
+	sendErr := sendOutboxEmail(message)
+	next := time.Now().Add(emailBackoff(message.Attempts))
+	switch {
+	case sendErr == nil:
+		err = repositories.MarkEmailSent(message.ID)
+	case utils.IsPermanentMailError(sendErr) || message.Attempts >= message.MaxAttempts:
+		log.Println("Email", message.ID, "to", message.Recipient, "dead-lettered after", message.Attempts, "attempts:", sendErr)
+		err = repositories.MarkEmailDead(message.ID, truncateError(sendErr))
+	case message.ExpiresAt != nil && next.After(*message.ExpiresAt):
+		log.Println("Email", message.ID, "to", message.Recipient, "dead-lettered: its link expires before the next attempt:", sendErr)
+		err = repositories.MarkEmailDead(message.ID, truncateError(sendErr))
+	default:
+		log.Println("Email", message.ID, "to", message.Recipient, "failed, retrying at", next.Format(time.RFC3339), ":", sendErr)
+		err = repositories.RetryEmailLater(message.ID, next, truncateError(sendErr))
+	}
+	if err != nil {
+		log.Println("Could not record delivery result for email", message.ID, err)
+	}
+	return true
+}
+
+// sendOutboxEmail decodes a queued message and hands it to the mailer
+func sendOutboxEmail(message *models.EmailOutbox) error {
+	var msg utils.EmailMessage
+	if err := json.Unmarshal([]byte(message.Payload), &msg); err != nil {
+		return errors.Join(utils.ErrInvalidEmail, err)
+	}
+	return utils.CurrentMailer().Send(msg)
+}
+
+// emailBackoff returns the delay before the next attempt after the given number of attempts,
+// doubling from emailBaseBackoff up to emailMaxBackoff with up to 20% jitter
+func emailBackoff(attempts int) time.Duration {
+	delay := emailBaseBackoff
+	for i := 1; i < attempts && delay < emailMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > emailMaxBackoff {
+		delay = emailMaxBackoff
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
+
+// truncateError keeps stored delivery errors to a readable size
+func truncateError(err error) string {
+	message := err.Error()
+	if len(message) > maxLastErrorLength {
+		message = message[:maxLastErrorLength]
+	}
+	return message
+}
@@ -0,0 +1,23 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/wanloq/taskinator/internal/repositories"
+)
+
+// StartWebAuthnSessionCleanup periodically deletes expired passkey ceremony sessions. Anyone can
+// start a passkey login without signing in, so abandoned sessions would otherwise accumulate.
+func StartWebAuthnSessionCleanup(interval time.Duration) {
+	go func() {
+		for {
+			if deleted, err := repositories.DeleteExpiredWebAuthnSessions(time.Now()); err != nil {
+				log.Println("Could not delete expired passkey sessions:", err)
+			} else if deleted > 0 {
+				log.Println("Deleted", deleted, "expired passkey sessions")
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
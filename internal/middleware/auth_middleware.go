@@ -1,13 +1,27 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/wanloq/taskinator/internal/dto"
+	"github.com/wanloq/taskinator/internal/models"
+	"github.com/wanloq/taskinator/internal/repositories"
 	"github.com/wanloq/taskinator/internal/utils"
 )
 
-// JWTMiddleware protects routes by verifying JWT tokens
+// Response headers that mark a request made through an impersonation token
+const (
+	ImpersonatedByHeader   = "X-Impersonated-By"
+	ImpersonatedUserHeader = "X-Impersonated-User"
+)
+
+// JWTMiddleware protects routes by verifying JWT tokens or personal access tokens
 func JWTMiddleware(c *fiber.Ctx) error {
 	authHeader := c.Get("Authorization")
 	if authHeader == "" {
@@ -21,34 +35,188 @@ func JWTMiddleware(c *fiber.Ctx) error {
 	}
 	tokenString := parts[1]
 
-	// Verify token
-	claims, err := utils.VerifyJWT(tokenString)
+	// Personal access tokens carry a distinct prefix; everything else is treated as a JWT
+	var principal *dto.Principal
+	var err error
+	if utils.IsPersonalAccessToken(tokenString) {
+		principal, err = authenticatePersonalAccessToken(tokenString)
+	} else {
+		principal, err = authenticateJWT(tokenString)
+	}
 	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired token"})
 	}
 
 	// Store user details in context
-	c.Locals("user_id", claims.UserID)
-	c.Locals("email", claims.Email)
-	c.Locals("role", claims.Role)
+	utils.SetPrincipal(c, principal)
 
+	if principal.ActorID != 0 {
+		return serveImpersonated(c, principal)
+	}
 	return c.Next()
 }
 
-func RoleMiddleware(requiredRole string) fiber.Handler {
+// serveImpersonated marks the response as impersonated, enforces read-only sessions
+// and attributes the request to the administrator behind it
+func serveImpersonated(c *fiber.Ctx, principal *dto.Principal) error {
+	c.Set(ImpersonatedByHeader, strconv.FormatUint(uint64(principal.ActorID), 10))
+	c.Set(ImpersonatedUserHeader, strconv.FormatUint(uint64(principal.UserID), 10))
+
+	var err error
+	if principal.ReadOnly && !isSafeMethod(c.Method()) {
+		err = c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "This impersonation session is read-only"})
+	} else {
+		err = c.Next()
+	}
+
+	actorID, targetID := principal.ActorID, principal.UserID
+	entry := models.AuditLog{
+		ActorID:    &actorID,
+		Action:     models.AuditImpersonatedRequest,
+		TargetType: models.AuditTargetUser,
+		TargetID:   &targetID,
+		Details:    fmt.Sprintf("%s %s -> %d", c.Method(), c.Path(), c.Response().StatusCode()),
+		IP:         c.IP(),
+	}
+	if auditErr := repositories.CreateAuditLog(&entry); auditErr != nil {
+		log.Println("Could not record impersonated request:", auditErr)
+	}
+	return err
+}
+
+// isSafeMethod reports whether an HTTP method only reads
+func isSafeMethod(method string) bool {
+	return method == fiber.MethodGet || method == fiber.MethodHead || method == fiber.MethodOptions
+}
+
+// authenticateJWT verifies a session JWT and resolves its principal
+func authenticateJWT(tokenString string) (*dto.Principal, error) {
+	claims, err := utils.VerifyJWT(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve the current role and permissions so role changes apply immediately
+	principal, err := loadPrincipal(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if claims.IssuedAt != nil {
+		principal.AuthenticatedAt = claims.IssuedAt.Time
+	}
+	if claims.ActorID == 0 {
+		return principal, nil
+	}
+
+	// Impersonation only works while the administrator still holds the permission
+	actor, err := loadPrincipal(claims.ActorID)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.HasPermission(models.PermUsersImpersonate) {
+		return nil, errors.New("impersonation no longer permitted")
+	}
+	principal.ActorID = claims.ActorID
+	principal.ReadOnly = claims.ReadOnly
+	return principal, nil
+}
+
+// authenticatePersonalAccessToken verifies a personal access token and resolves its principal,
+// limiting the owner's permissions to the token's scopes
+func authenticatePersonalAccessToken(tokenString string) (*dto.Principal, error) {
+	token, err := repositories.GetPersonalAccessTokenByHash(utils.HashToken(tokenString))
+	if err != nil {
+		return nil, err
+	}
+	if !token.IsActive(time.Now()) {
+		return nil, errors.New("token revoked or expired")
+	}
+
+	principal, err := loadPrincipal(token.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := make(map[string]bool)
+	for _, scope := range token.ScopeList() {
+		scopes[scope] = true
+	}
+	permissions := []string{}
+	for _, permission := range principal.Permissions {
+		if scopes[permission] {
+			permissions = append(permissions, permission)
+		}
+	}
+	// Self-service scopes do not depend on the owner's role
+	for _, permission := range models.SelfServicePermissions {
+		if scopes[permission] {
+			permissions = append(permissions, permission)
+		}
+	}
+	principal.Permissions = permissions
+	principal.TokenID = token.ID
+
+	if err := repositories.TouchPersonalAccessToken(token.ID); err != nil {
+		log.Println("Could not record token usage:", err)
+	}
+	return principal, nil
+}
+
+// loadPrincipal builds the request principal from the user's current database state
+func loadPrincipal(userID uint) (*dto.Principal, error) {
+	user, err := repositories.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	// Suspending or locking an account also cuts off the sessions and tokens it already has
+	if user.IsBlocked(time.Now()) {
+		return nil, errors.New("account " + user.Status)
+	}
+
+	principal := &dto.Principal{
+		UserID: user.ID,
+		Email:  user.Email,
+		Role:   user.Role,
+	}
+
+	// A role missing from the roles table grants no permissions
+	if role, err := repositories.GetRoleByName(user.Role); err == nil {
+		principal.Permissions = role.PermissionNames()
+	}
+	return principal, nil
+}
+
+// RequirePermission restricts a route to callers whose role grants every listed permission.
+// It must be mounted after JWTMiddleware.
+func RequirePermission(permissions ...string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		userID, role, err := utils.ExtractUserFromToken(c)
+		principal, err := utils.GetPrincipal(c)
 		if err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
 		}
 
-		// Check if the user's role matches the required role
-		if role != requiredRole {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Access denied"})
+		for _, permission := range permissions {
+			if !principal.HasPermission(permission) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Access denied"})
+			}
 		}
 
-		// Allow the request to proceed
-		c.Locals("userID", userID)
+		return c.Next()
+	}
+}
+
+// RequireTokenScope guards a route on the caller's own data. Every account may use it from a login
+// session, whatever its role; a personal access token must carry the given self-service scope.
+// It must be mounted after JWTMiddleware.
+func RequireTokenScope(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal, err := utils.GetPrincipal(c)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+		}
+		if principal.TokenID != 0 && !principal.HasPermission(permission) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Token scope does not allow this"})
+		}
 		return c.Next()
 	}
 }
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Audited actions
+const (
+	AuditUserRoleChanged      = "user.role_changed"
+	AuditUserVerified         = "user.verified"
+	AuditUserSuspended        = "user.suspended"
+	AuditUserLocked           = "user.locked"
+	AuditUserReinstated       = "user.reinstated"
+	AuditUserUnlocked         = "user.unlocked"
+	AuditUserPasswordReset    = "user.password_reset_requested"
+	AuditUserDeleted          = "user.deleted"
+	AuditUserImported         = "user.imported"
+	AuditUsersExported        = "users.exported"
+	AuditImpersonationStarted = "impersonation.started"
+	AuditImpersonatedRequest  = "impersonation.request"
+	AuditEmailRequeued        = "email.requeued"
+	AuditAdminActionRefused   = "admin.action_refused"
+)
+
+// Audit target types
+const (
+	AuditTargetUser = "user"
+)
+
+// AuditLog represents the audit_logs table: one administrative action and who performed it
+type AuditLog struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ActorID    *uint     `json:"actor_id"`
+	Action     string    `gorm:"not null" json:"action"`
+	TargetType string    `gorm:"not null" json:"target_type"`
+	TargetID   *uint     `json:"target_id"`
+	Details    string    `gorm:"not null;default:''" json:"details,omitempty"`
+	IP         string    `gorm:"not null;default:''" json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Digest frequencies
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// IsValidDigestFrequency reports whether frequency is a known digest frequency
+func IsValidDigestFrequency(frequency string) bool {
+	return frequency == DigestOff || frequency == DigestDaily || frequency == DigestWeekly
+}
+
+// DigestEnabled reports whether the user receives a digest instead of an email per event
+func (u *User) DigestEnabled() bool {
+	return u.DigestFrequency == DigestDaily || u.DigestFrequency == DigestWeekly
+}
+
+// DigestDelivery represents the digest_deliveries table: the digest for one user and local day has been handled
+type DigestDelivery struct {
+	UserID     uint      `gorm:"primaryKey"`
+	DigestDate time.Time `gorm:"primaryKey;type:date"`
+	ItemCount  int       `gorm:"not null;default:0"`
+	CreatedAt  time.Time
+}
+
+// DigestDueOn reports whether a user's digest is due at local, the current time in their timezone:
+// on their send day, once their send hour has been reached
+func (u *User) DigestDueOn(local time.Time) bool {
+	switch u.DigestFrequency {
+	case DigestDaily:
+		return local.Hour() >= u.DigestHour
+	case DigestWeekly:
+		return int(local.Weekday()) == u.DigestWeekday && local.Hour() >= u.DigestHour
+	}
+	return false
+}
+
+// DigestPeriod is how far back a digest looks when the user has not received one before
+func (u *User) DigestPeriod() time.Duration {
+	if u.DigestFrequency == DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
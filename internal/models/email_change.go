@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// EmailChange represents the email_changes table: a requested change of a user's email address.
+// The change only takes effect once the new address confirms it, and the old address
+// can revert it until RevertExpiresAt. Only token hashes are stored.
+type EmailChange struct {
+	ID               uint      `gorm:"primaryKey"`
+	UserID           uint      `gorm:"not null"`
+	OldEmail         string    `gorm:"not null"`
+	NewEmail         string    `gorm:"not null"`
+	OldVerified      bool      `gorm:"not null;default:false"`
+	ConfirmTokenHash string    `gorm:"unique;not null"`
+	RevertTokenHash  string    `gorm:"unique;not null"`
+	ExpiresAt        time.Time `gorm:"not null"`
+	RevertExpiresAt  time.Time `gorm:"not null"`
+	ConfirmedAt      *time.Time
+	RevertedAt       *time.Time
+	CancelledAt      *time.Time
+	CreatedAt        time.Time
+}
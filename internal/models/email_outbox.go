@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Outbox message statuses
+const (
+	OutboxPending = "pending"
+	OutboxSending = "sending"
+	OutboxSent    = "sent"
+	OutboxDead    = "dead"
+)
+
+// EmailOutbox represents the email_outbox table: an email waiting to be delivered, or the record
+// of one that was. Payload holds the serialized message and is cleared once it is sent, or once a
+// dead-lettered message's link expires, since it may contain single-use links.
+type EmailOutbox struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	Recipient     string     `gorm:"not null" json:"recipient"`
+	Subject       string     `gorm:"not null" json:"subject"`
+	Payload       string     `gorm:"not null" json:"-"`
+	Status        string     `gorm:"not null;default:pending" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts   int        `gorm:"not null" json:"max_attempts"`
+	NextAttemptAt time.Time  `gorm:"not null" json:"next_attempt_at"`
+	LockedUntil   *time.Time `json:"-"`
+	LastError     string     `gorm:"not null;default:''" json:"last_error,omitempty"`
+	// ExpiresAt is when the link in the message stops working; it is never delivered after that
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	SentAt    *time.Time `json:"sent_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// TableName keeps the singular table name
+func (EmailOutbox) TableName() string {
+	return "email_outbox"
+}
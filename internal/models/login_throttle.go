@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Login throttle scopes
+const (
+	ThrottleScopeAccount = "account"
+	ThrottleScopeIP      = "ip"
+)
+
+// LoginThrottle represents the login_throttles table: recent failed logins for one account or IP.
+// Accounts are keyed by normalized email so unknown addresses are throttled exactly like real ones.
+type LoginThrottle struct {
+	ID            uint      `gorm:"primaryKey"`
+	Scope         string    `gorm:"not null"`
+	Key           string    `gorm:"not null"`
+	Failures      int       `gorm:"not null"`
+	LastFailureAt time.Time `gorm:"not null"`
+	LockedUntil   *time.Time
+}
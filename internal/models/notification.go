@@ -0,0 +1,75 @@
+package models
+
+import "time"
+
+// Notification event types
+const (
+	NotificationAssignment  = "assignment"
+	NotificationMention     = "mention"
+	NotificationDueReminder = "due_reminder"
+	NotificationSecurity    = "security"
+)
+
+// Notification channels
+const (
+	ChannelInApp = "in_app"
+	ChannelEmail = "email"
+)
+
+// NotificationTypes lists every event type users can set preferences for
+var NotificationTypes = []string{NotificationAssignment, NotificationMention, NotificationDueReminder, NotificationSecurity}
+
+// Notification represents the notifications table: one entry in a user's in-app notification center
+type Notification struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null" json:"-"`
+	Type      string     `gorm:"not null" json:"type"`
+	Title     string     `gorm:"not null" json:"title"`
+	Body      string     `gorm:"not null;default:''" json:"body"`
+	Link      string     `gorm:"not null;default:''" json:"link,omitempty"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NotificationPreference represents the notification_preferences table: the channels a user
+// receives one event type on. Without a row, DefaultNotificationPreference applies.
+type NotificationPreference struct {
+	UserID    uint      `gorm:"primaryKey" json:"-"`
+	EventType string    `gorm:"primaryKey" json:"event_type"`
+	InApp     bool      `gorm:"not null" json:"-"`
+	Email     bool      `gorm:"not null" json:"-"`
+	UpdatedAt time.Time `json:"-"`
+}
+
+// IsValidNotificationType reports whether eventType is a known event type
+func IsValidNotificationType(eventType string) bool {
+	for _, known := range NotificationTypes {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultNotificationPreference is used until a user sets their own: every event is shown in-app and emailed
+func DefaultNotificationPreference(userID uint, eventType string) NotificationPreference {
+	return NotificationPreference{UserID: userID, EventType: eventType, InApp: true, Email: true}
+}
+
+// EmailRequired reports whether an event type is always emailed: security alerts tell the owner
+// about account changes they may need to undo
+func EmailRequired(eventType string) bool {
+	return eventType == NotificationSecurity
+}
+
+// Channels lists the enabled channels
+func (p NotificationPreference) Channels() []string {
+	channels := []string{}
+	if p.InApp {
+		channels = append(channels, ChannelInApp)
+	}
+	if p.Email {
+		channels = append(channels, ChannelEmail)
+	}
+	return channels
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// One-time token purposes
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposeMagicLogin        = "magic_login"
+	TokenPurposeInvitation        = "invitation"
+)
+
+// OneTimeToken represents the one_time_tokens table: a hashed, single-use token emailed to a user.
+// A token is only accepted for the purpose it was issued for, once, before it expires.
+// BindingHash, when set, ties the token to a secret held by the requesting browser.
+type OneTimeToken struct {
+	ID          uint      `gorm:"primaryKey"`
+	UserID      uint      `gorm:"not null"`
+	Purpose     string    `gorm:"not null"`
+	TokenHash   string    `gorm:"unique;not null"`
+	ExpiresAt   time.Time `gorm:"not null"`
+	ConsumedAt  *time.Time
+	BindingHash *string
+	CreatedAt   time.Time
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Resource types that can be shared
+const (
+	ResourceTask    = "task"
+	ResourceProject = "project"
+)
+
+// Access levels, from least to most privileged
+const (
+	AccessViewer = "viewer"
+	AccessEditor = "editor"
+	AccessOwner  = "owner"
+)
+
+var accessRank = map[string]int{
+	AccessViewer: 1,
+	AccessEditor: 2,
+	AccessOwner:  3,
+}
+
+// ResourceGrant represents the resource_grants table: one user's access to one task or project.
+// The creator of a resource holds an owner grant.
+type ResourceGrant struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	ResourceType string    `gorm:"not null" json:"resource_type"`
+	ResourceID   uint      `gorm:"not null" json:"resource_id"`
+	UserID       uint      `gorm:"not null" json:"user_id"`
+	Access       string    `gorm:"not null" json:"access"`
+	GrantedBy    *uint     `json:"granted_by"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// AccessSatisfies reports whether the granted level is at least the required level
+func AccessSatisfies(granted, required string) bool {
+	return accessRank[granted] >= accessRank[required] && accessRank[required] > 0
+}
@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+// Built-in role names
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// Permission names granted to roles
+const (
+	PermTasksRead        = "tasks:read"
+	PermTasksWrite       = "tasks:write"
+	PermTasksDelete      = "tasks:delete"
+	PermTasksManage      = "tasks:manage"
+	PermProjectsRead     = "projects:read"
+	PermProjectsWrite    = "projects:write"
+	PermProjectsDelete   = "projects:delete"
+	PermProjectsManage   = "projects:manage"
+	PermUsersRead        = "users:read"
+	PermUsersWrite       = "users:write"
+	PermUsersDelete      = "users:delete"
+	PermRolesManage      = "roles:manage"
+	PermUsersImpersonate = "users:impersonate"
+	// Self-service permissions are not granted to roles: every account may reach its own profile and inbox,
+	// and these only restrict what a personal access token may do there
+	PermProfileRead        = "profile:read"
+	PermProfileWrite       = "profile:write"
+	PermNotificationsRead  = "notifications:read"
+	PermNotificationsWrite = "notifications:write"
+)
+
+// SelfServicePermissions lists the permissions every account holds over its own data
+var SelfServicePermissions = []string{PermProfileRead, PermProfileWrite, PermNotificationsRead, PermNotificationsWrite}
+
+// IsSelfServicePermission reports whether permission covers the caller's own data rather than a role grant
+func IsSelfServicePermission(permission string) bool {
+	for _, selfService := range SelfServicePermissions {
+		if selfService == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Role represents the roles table
+type Role struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	Name        string       `gorm:"unique;not null" json:"name"`
+	Description string       `json:"description"`
+	Permissions []Permission `gorm:"many2many:role_permissions" json:"permissions"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// Permission represents the permissions table
+type Permission struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	Name        string `gorm:"unique;not null" json:"name"`
+	Description string `json:"description"`
+}
+
+// PermissionNames returns the names of the permissions granted to the role
+func (r *Role) PermissionNames() []string {
+	names := make([]string, 0, len(r.Permissions))
+	for _, p := range r.Permissions {
+		names = append(names, p.Name)
+	}
+	return names
+}
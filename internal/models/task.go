@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Task represents the tasks table. Who may see and change a task is decided by its resource grants;
+// its creator holds the owner grant.
+type Task struct {
+	ID     uint   `gorm:"primaryKey" json:"id"`
+	Name   string `gorm:"not null" json:"name"`
+	Status bool   `gorm:"not null;default:false" json:"status"`
+	// DueAt, when set, puts the open task in its owners' and editors' digests from its due day on
+	DueAt     *time.Time `json:"due_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
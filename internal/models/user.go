@@ -1,13 +1,74 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Account statuses. Suspended and locked accounts cannot log in or use existing tokens;
+// suspension is a policy decision, a lock a security measure. Pending deletion accounts
+// still work, and logging in cancels the deletion.
+const (
+	UserStatusActive          = "active"
+	UserStatusSuspended       = "suspended"
+	UserStatusLocked          = "locked"
+	UserStatusPendingDeletion = "pending_deletion"
+)
+
+// IsValidUserStatus reports whether status is a known account status
+func IsValidUserStatus(status string) bool {
+	switch status {
+	case UserStatusActive, UserStatusSuspended, UserStatusLocked, UserStatusPendingDeletion:
+		return true
+	}
+	return false
+}
 
 // User represents the users table
 type User struct {
 	gorm.Model
-	Username     string `gorm:"unique;not null"`
-	Email        string `gorm:"unique;not null"`
-	PasswordHash string `gorm:"not null"`
-	Role         string `gorm:"default:user"`
-	IsVerified   bool   `gorm:"default:false"`
+	Username        string `gorm:"unique;not null"`
+	Email           string `gorm:"unique;not null"`
+	PasswordHash    string `gorm:"not null"`
+	Role            string `gorm:"default:user"`
+	IsVerified      bool   `gorm:"default:false"`
+	DisplayName     string `gorm:"not null;default:''"`
+	Timezone        string `gorm:"not null;default:UTC"`
+	Locale          string `gorm:"not null;default:en"`
+	AvatarUpdatedAt *time.Time
+	Status          string `gorm:"not null;default:active"`
+	StatusReason    string `gorm:"not null;default:''"`
+	// StatusUntil ends a suspension or lock, or is when a pending deletion takes effect
+	StatusUntil *time.Time
+	// DigestFrequency, DigestHour (0-23) and DigestWeekday (0 = Sunday, weekly digests only)
+	// schedule the digest email in the user's timezone
+	DigestFrequency string `gorm:"not null;default:off"`
+	DigestHour      int    `gorm:"not null;default:8"`
+	DigestWeekday   int    `gorm:"not null;default:1"`
+}
+
+// EffectiveStatus returns the user's status at the given time; suspensions and locks lapse at StatusUntil
+func (u *User) EffectiveStatus(now time.Time) string {
+	if (u.Status == UserStatusSuspended || u.Status == UserStatusLocked) && u.StatusUntil != nil && !now.Before(*u.StatusUntil) {
+		return UserStatusActive
+	}
+	if u.Status == "" {
+		return UserStatusActive
+	}
+	return u.Status
+}
+
+// IsBlocked reports whether the user may neither log in nor use existing sessions and tokens
+func (u *User) IsBlocked(now time.Time) bool {
+	status := u.EffectiveStatus(now)
+	return status == UserStatusSuspended || status == UserStatusLocked
+}
+
+// UserAvatar represents the user_avatars table: a user's resized profile picture
+type UserAvatar struct {
+	UserID      uint   `gorm:"primaryKey"`
+	ContentType string `gorm:"not null"`
+	Data        []byte `gorm:"not null"`
+	UpdatedAt   time.Time
 }
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// UserIdentity represents the user_identities table: an external identity provider account linked to a user
+type UserIdentity struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null" json:"user_id"`
+	Provider  string    `gorm:"not null" json:"provider"`
+	Subject   string    `gorm:"not null" json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
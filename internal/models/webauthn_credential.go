@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// WebAuthn ceremony purposes
+const (
+	WebAuthnPurposeRegistration = "registration"
+	WebAuthnPurposeLogin        = "login"
+)
+
+// WebAuthnCredential represents the webauthn_credentials table: a passkey registered by a user.
+// CloneWarning is set when the authenticator's signature counter goes backwards; such credentials are refused.
+type WebAuthnCredential struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	UserID          uint       `gorm:"not null" json:"-"`
+	Name            string     `json:"name"`
+	CredentialID    []byte     `gorm:"unique;not null" json:"-"`
+	PublicKey       []byte     `gorm:"not null" json:"-"`
+	AttestationType string     `json:"-"`
+	Transports      string     `json:"transports"`
+	Flags           uint8      `json:"-"`
+	AAGUID          []byte     `gorm:"column:aaguid" json:"-"`
+	SignCount       uint32     `json:"-"`
+	CloneWarning    bool       `json:"clone_warning"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// WebAuthnSession represents the webauthn_sessions table: the server-side state of an
+// in-progress registration or login ceremony, redeemable once
+type WebAuthnSession struct {
+	ID             uint   `gorm:"primaryKey"`
+	SessionKeyHash string `gorm:"unique;not null"`
+	Purpose        string `gorm:"not null"`
+	UserID         *uint
+	Data           string    `gorm:"not null"`
+	ExpiresAt      time.Time `gorm:"not null"`
+	CreatedAt      time.Time
+}
+
+// TableName keeps GORM from splitting "WebAuthn" into "web_authn"
+func (WebAuthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
+
+// TableName keeps GORM from splitting "WebAuthn" into "web_authn"
+func (WebAuthnSession) TableName() string {
+	return "webauthn_sessions"
+}
@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/models"
+	"gorm.io/gorm"
+)
+
+// deletedPasswordHash is stored for purged accounts; no password hasher recognises it, so no password matches
+const deletedPasswordHash = "!deleted"
+
+// ScheduleUserDeletion marks a user as pending deletion at the given time
+func ScheduleUserDeletion(userID uint, at time.Time) error {
+	return SetUserStatus(userID, models.UserStatusPendingDeletion, "Requested by the account owner", &at)
+}
+
+// CancelUserDeletion makes a user pending deletion active again, returning whether a deletion was cancelled
+func CancelUserDeletion(userID uint) (bool, error) {
+	result := config.DB.Model(&models.User{}).
+		Where("id = ? AND status = ?", userID, models.UserStatusPendingDeletion).
+		Updates(map[string]interface{}{"status": models.UserStatusActive, "status_reason": "", "status_until": nil})
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetUsersDueForDeletion retrieves up to limit users whose deletion grace period has ended
+func GetUsersDueForDeletion(now time.Time, limit int) ([]models.User, error) {
+	var users []models.User
+	err := config.DB.Where("status = ? AND status_until <= ?", models.UserStatusPendingDeletion, now).
+		Order("status_until").Limit(limit).Find(&users).Error
+	return users, err
+}
+
+// GetUserIdentitiesByUser retrieves the external identities linked to a user
+func GetUserIdentitiesByUser(userID uint) ([]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	err := config.DB.Where("user_id = ?", userID).Order("created_at").Find(&identities).Error
+	return identities, err
+}
+
+// GetResourceGrantsByUser retrieves every grant held by a user
+func GetResourceGrantsByUser(userID uint) ([]models.ResourceGrant, error) {
+	var grants []models.ResourceGrant
+	err := config.DB.Where("user_id = ?", userID).Order("resource_type, resource_id").Find(&grants).Error
+	return grants, err
+}
+
+// PurgeUser removes everything owned by a user, including email queued for or sent to any of
+// their addresses, and anonymises the account row.
+// Resources the user solely owned lose all of their grants, so nobody can reach them any more;
+// resources shared with another owner are kept. The anonymised row is soft-deleted so
+// references such as granted_by stay valid without identifying the user.
+func PurgeUser(userID uint) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Unscoped().First(&user, userID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Exec(`
+			DELETE FROM resource_grants g
+			USING resource_grants mine
+			WHERE mine.user_id = ? AND mine.access = ?
+			  AND g.resource_type = mine.resource_type AND g.resource_id = mine.resource_id
+			  AND NOT EXISTS (
+				SELECT 1 FROM resource_grants other
+				WHERE other.resource_type = mine.resource_type AND other.resource_id = mine.resource_id
+				  AND other.access = ? AND other.user_id <> ?
+			  )`, userID, models.AccessOwner, models.AccessOwner, userID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ResourceGrant{}).Where("granted_by = ?", userID).Update("granted_by", nil).Error; err != nil {
+			return err
+		}
+
+		// Email to any address the account used would otherwise keep its name and links around
+		var changes []models.EmailChange
+		if err := tx.Select("old_email", "new_email").Where("user_id = ?", userID).Find(&changes).Error; err != nil {
+			return err
+		}
+		recipients := []string{strings.ToLower(user.Email)}
+		for _, change := range changes {
+			recipients = append(recipients, strings.ToLower(change.OldEmail), strings.ToLower(change.NewEmail))
+		}
+		if err := tx.Where("LOWER(recipient) IN ?", recipients).Delete(&models.EmailOutbox{}).Error; err != nil {
+			return err
+		}
+
+		for _, owned := range []interface{}{
+			&models.ResourceGrant{},
+			&models.PersonalAccessToken{},
+			&models.UserIdentity{},
+			&models.WebAuthnCredential{},
+			&models.WebAuthnSession{},
+			&models.OneTimeToken{},
+			&models.EmailChange{},
+			&models.UserAvatar{},
+			&models.Notification{},
+			&models.NotificationPreference{},
+			&models.DigestDelivery{},
+		} {
+			if err := tx.Where("user_id = ?", userID).Delete(owned).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("scope = ? AND key = ?", models.ThrottleScopeAccount, strings.ToLower(strings.TrimSpace(user.Email))).
+			Delete(&models.LoginThrottle{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"username":          fmt.Sprintf("deleted-user-%d", userID),
+			"email":             fmt.Sprintf("deleted-user-%d@deleted.invalid", userID),
+			"password_hash":     deletedPasswordHash,
+			"is_verified":       false,
+			"display_name":      "",
+			"avatar_updated_at": nil,
+			"status":            models.UserStatusActive,
+			"status_reason":     "",
+			"status_until":      nil,
+			"deleted_at":        time.Now(),
+		}).Error
+	})
+}
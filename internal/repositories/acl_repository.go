@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/models"
+	"gorm.io/gorm/clause"
+)
+
+// GetResourceGrant retrieves a user's grant on a resource
+func GetResourceGrant(resourceType string, resourceID, userID uint) (*models.ResourceGrant, error) {
+	var grant models.ResourceGrant
+	result := config.DB.Where("resource_type = ? AND resource_id = ? AND user_id = ?", resourceType, resourceID, userID).First(&grant)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &grant, nil
+}
+
+// GetResourceGrants retrieves every grant on a resource
+func GetResourceGrants(resourceType string, resourceID uint) ([]models.ResourceGrant, error) {
+	var grants []models.ResourceGrant
+	result := config.DB.Where("resource_type = ? AND resource_id = ?", resourceType, resourceID).Order("id").Find(&grants)
+	return grants, result.Error
+}
+
+// SaveResourceGrant creates a grant or updates the access level of an existing one
+func SaveResourceGrant(grant *models.ResourceGrant) error {
+	return config.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "resource_type"}, {Name: "resource_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"access", "granted_by", "updated_at"}),
+	}).Create(grant).Error
+}
+
+// DeleteResourceGrant removes a user's grant on a resource
+func DeleteResourceGrant(resourceType string, resourceID, userID uint) error {
+	return config.DB.Where("resource_type = ? AND resource_id = ? AND user_id = ?", resourceType, resourceID, userID).Delete(&models.ResourceGrant{}).Error
+}
+
+// DeleteResourceGrants removes every grant on a resource, used when the resource is deleted
+func DeleteResourceGrants(resourceType string, resourceID uint) error {
+	return config.DB.Where("resource_type = ? AND resource_id = ?", resourceType, resourceID).Delete(&models.ResourceGrant{}).Error
+}
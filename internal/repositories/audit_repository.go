@@ -0,0 +1,46 @@
+package repositories
+
+import (
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/models"
+)
+
+// AuditLogFilter narrows an audit log listing; zero values match everything
+type AuditLogFilter struct {
+	ActorID    uint
+	TargetType string
+	TargetID   uint
+	Action     string
+	Offset     int
+	Limit      int
+}
+
+// CreateAuditLog records an audited action
+func CreateAuditLog(entry *models.AuditLog) error {
+	return config.DB.Create(entry).Error
+}
+
+// ListAuditLogs retrieves one page of audit entries, newest first, with the total number of matches
+func ListAuditLogs(filter AuditLogFilter) ([]models.AuditLog, int64, error) {
+	query := config.DB.Model(&models.AuditLog{})
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var entries []models.AuditLog
+	err := query.Order("created_at DESC, id DESC").Offset(filter.Offset).Limit(filter.Limit).Find(&entries).Error
+	return entries, total, err
+}
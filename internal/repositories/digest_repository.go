@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/models"
+	"gorm.io/gorm"
+)
+
+// digestDateLayout formats a local calendar day for the digest_date column
+const digestDateLayout = "2006-01-02"
+
+// GetDigestSubscribers retrieves up to limit users with a digest enabled and an ID above afterID, by ID
+func GetDigestSubscribers(afterID uint, limit int) ([]models.User, error) {
+	var users []models.User
+	err := config.DB.Where("digest_frequency <> ? AND id > ?", models.DigestOff, afterID).
+		Order("id").Limit(limit).Find(&users).Error
+	return users, err
+}
+
+// ClaimDigest records that the user's digest for the given local day is being handled. It returns
+// false when another run or replica already claimed it.
+func ClaimDigest(userID uint, day time.Time) (bool, error) {
+	result := config.DB.Exec(
+		"INSERT INTO digest_deliveries (user_id, digest_date, created_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING",
+		userID, day.Format(digestDateLayout), time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// CompleteDigest stores how many items a claimed digest contained
+func CompleteDigest(userID uint, day time.Time, itemCount int) error {
+	return config.DB.Model(&models.DigestDelivery{}).
+		Where("user_id = ? AND digest_date = ?", userID, day.Format(digestDateLayout)).
+		Update("item_count", itemCount).Error
+}
+
+// ReleaseDigest removes a claim whose digest could not be sent, so a later run retries it
+func ReleaseDigest(userID uint, day time.Time) error {
+	return config.DB.Where("user_id = ? AND digest_date = ?", userID, day.Format(digestDateLayout)).
+		Delete(&models.DigestDelivery{}).Error
+}
+
+// GetPreviousDigestAt returns when the user's last digest before the given local day was handled, or nil
+func GetPreviousDigestAt(userID uint, day time.Time) (*time.Time, error) {
+	var delivery models.DigestDelivery
+	err := config.DB.Where("user_id = ? AND digest_date < ?", userID, day.Format(digestDateLayout)).
+		Order("digest_date DESC").First(&delivery).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &delivery.CreatedAt, nil
+}
+
+// GetNotificationsSince retrieves up to limit of the user's notifications created after since, oldest first
+func GetNotificationsSince(userID uint, since time.Time, limit int) ([]models.Notification, error) {
+	var notifications []models.Notification
+	err := config.DB.Where("user_id = ? AND created_at > ?", userID, since).
+		Order("created_at, id").Limit(limit).Find(&notifications).Error
+	return notifications, err
+}
+
+// CountNotificationsSince counts the user's notifications created after since
+func CountNotificationsSince(userID uint, since time.Time) (int64, error) {
+	var count int64
+	err := config.DB.Model(&models.Notification{}).Where("user_id = ? AND created_at > ?", userID, since).Count(&count).Error
+	return count, err
+}
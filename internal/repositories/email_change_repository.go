@@ -0,0 +1,156 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrEmailTaken is returned when an email change would collide with another account
+var ErrEmailTaken = errors.New("email already in use")
+
+// ErrEmailChangedSince is returned when a revert finds the account no longer uses the address it changed to
+var ErrEmailChangedSince = errors.New("email changed again since")
+
+// addressBoundTokenPurposes are the one-time tokens that were emailed to an address and stop working when it changes
+var addressBoundTokenPurposes = []string{
+	models.TokenPurposeEmailVerification,
+	models.TokenPurposePasswordReset,
+	models.TokenPurposeMagicLogin,
+}
+
+// CreateEmailChange stores a new pending email change, cancelling the user's other pending changes
+func CreateEmailChange(change *models.EmailChange) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.EmailChange{}).
+			Where("user_id = ? AND confirmed_at IS NULL AND cancelled_at IS NULL", change.UserID).
+			Update("cancelled_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(change).Error
+	})
+}
+
+// GetConfirmableEmailChange returns the pending email change a confirmation token would apply, without applying it
+func GetConfirmableEmailChange(confirmTokenHash string) (*models.EmailChange, error) {
+	var change models.EmailChange
+	err := config.DB.
+		Where("confirm_token_hash = ? AND confirmed_at IS NULL AND cancelled_at IS NULL AND expires_at > now()", confirmTokenHash).
+		First(&change).Error
+	if err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// GetRevertableEmailChange returns the email change a revert token would undo, without undoing it
+func GetRevertableEmailChange(revertTokenHash string) (*models.EmailChange, error) {
+	var change models.EmailChange
+	err := config.DB.
+		Where("revert_token_hash = ? AND reverted_at IS NULL AND revert_expires_at > now()", revertTokenHash).
+		First(&change).Error
+	if err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// ConfirmEmailChange atomically applies a pending email change and marks the new address verified.
+// Verification, password reset and login links sent to the previous address stop working.
+func ConfirmEmailChange(confirmTokenHash string) (*models.EmailChange, error) {
+	var change models.EmailChange
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Raw(`
+			UPDATE email_changes SET confirmed_at = now()
+			WHERE confirm_token_hash = ? AND confirmed_at IS NULL AND cancelled_at IS NULL AND expires_at > now()
+			RETURNING *`, confirmTokenHash).Scan(&change)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		var taken int64
+		if err := tx.Model(&models.User{}).Where("LOWER(email) = LOWER(?) AND id <> ?", change.NewEmail, change.UserID).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ErrEmailTaken
+		}
+
+		result = tx.Model(&models.User{}).
+			Where("id = ? AND email = ?", change.UserID, change.OldEmail).
+			Updates(map[string]interface{}{"email": change.NewEmail, "is_verified": true})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// The address changed some other way since the request was made
+			return gorm.ErrRecordNotFound
+		}
+
+		return invalidateAddressBoundTokens(tx, change.UserID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// RevertEmailChange atomically undoes an email change from the old address.
+// A pending change is cancelled; a confirmed one restores the old address and its verification state,
+// and links sent to the new address stop working. It returns ErrEmailChangedSince when the account
+// has moved on from the new address.
+func RevertEmailChange(revertTokenHash string) (*models.EmailChange, error) {
+	var change models.EmailChange
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Raw(`
+			UPDATE email_changes SET reverted_at = now(), cancelled_at = COALESCE(cancelled_at, now())
+			WHERE revert_token_hash = ? AND reverted_at IS NULL AND revert_expires_at > now()
+			RETURNING *`, revertTokenHash).Scan(&change)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if change.ConfirmedAt == nil {
+			return nil
+		}
+
+		var taken int64
+		if err := tx.Model(&models.User{}).Where("LOWER(email) = LOWER(?) AND id <> ?", change.OldEmail, change.UserID).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ErrEmailTaken
+		}
+
+		result = tx.Model(&models.User{}).
+			Where("id = ? AND email = ?", change.UserID, change.NewEmail).
+			Updates(map[string]interface{}{"email": change.OldEmail, "is_verified": change.OldVerified})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrEmailChangedSince
+		}
+
+		return invalidateAddressBoundTokens(tx, change.UserID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// invalidateAddressBoundTokens consumes the user's outstanding tokens that were emailed to their previous address
+func invalidateAddressBoundTokens(tx *gorm.DB, userID uint) error {
+	return tx.Model(&models.OneTimeToken{}).
+		Where("user_id = ? AND purpose IN ? AND consumed_at IS NULL", userID, addressBoundTokenPurposes).
+		Update("consumed_at", time.Now()).Error
+}
@@ -0,0 +1,187 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrEmailExpired is returned when requeueing a message whose link has already expired
+var ErrEmailExpired = errors.New("email link expired")
+
+// OutboxStats summarises the email queue
+type OutboxStats struct {
+	Pending int64 `json:"pending"`
+	Sending int64 `json:"sending"`
+	Sent    int64 `json:"sent"`
+	Dead    int64 `json:"dead"`
+	// Retrying counts pending messages that have already failed at least once
+	Retrying int64 `json:"retrying"`
+	// OldestPendingAt is when the longest-waiting undelivered message was queued
+	OldestPendingAt *time.Time `json:"oldest_pending_at"`
+}
+
+// EnqueueEmail stores a message for delivery by the outbox workers
+func EnqueueEmail(message *models.EmailOutbox) error {
+	return config.DB.Create(message).Error
+}
+
+// ClaimEmail atomically takes the next due message for delivery and counts the attempt.
+// A message whose worker died mid-delivery becomes claimable again once its lock expires.
+func ClaimEmail(lockFor time.Duration) (*models.EmailOutbox, error) {
+	var message models.EmailOutbox
+	now := time.Now()
+	result := config.DB.Raw(`
+		UPDATE email_outbox SET status = ?, attempts = attempts + 1, locked_until = ?, updated_at = ?
+		WHERE id = (
+			SELECT id FROM email_outbox
+			WHERE (status = ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?)
+			ORDER BY next_attempt_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING *`,
+		models.OutboxSending, now.Add(lockFor), now,
+		models.OutboxPending, now, models.OutboxSending, now).Scan(&message)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &message, nil
+}
+
+// MarkEmailSent records a delivered message and drops its payload
+func MarkEmailSent(id uint) error {
+	return config.DB.Model(&models.EmailOutbox{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":       models.OutboxSent,
+		"sent_at":      time.Now(),
+		"locked_until": nil,
+		"last_error":   "",
+		"payload":      "",
+	}).Error
+}
+
+// RetryEmailLater puts a failed message back in the queue until the given time
+func RetryEmailLater(id uint, next time.Time, lastError string) error {
+	return config.DB.Model(&models.EmailOutbox{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":          models.OutboxPending,
+		"next_attempt_at": next,
+		"locked_until":    nil,
+		"last_error":      lastError,
+	}).Error
+}
+
+// MarkEmailDead dead-letters a message that cannot be delivered. The payload is kept so the message
+// can be requeued, unless its link has already expired and it never can be.
+func MarkEmailDead(id uint, lastError string) error {
+	return config.DB.Model(&models.EmailOutbox{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":       models.OutboxDead,
+		"locked_until": nil,
+		"last_error":   lastError,
+		"payload":      gorm.Expr("CASE WHEN expires_at <= now() THEN '' ELSE payload END"),
+	}).Error
+}
+
+// RequeueDeadEmail gives a dead-lettered message a fresh set of attempts, returning the number requeued.
+// A message whose link has expired is not requeued and ErrEmailExpired is returned.
+func RequeueDeadEmail(id uint) (int64, error) {
+	now := time.Now()
+	result := config.DB.Model(&models.EmailOutbox{}).
+		Where("id = ? AND status = ? AND (expires_at IS NULL OR expires_at > ?)", id, models.OutboxDead, now).
+		Updates(map[string]interface{}{
+			"status":          models.OutboxPending,
+			"attempts":        0,
+			"next_attempt_at": now,
+		})
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.RowsAffected, result.Error
+	}
+
+	var expired int64
+	if err := config.DB.Model(&models.EmailOutbox{}).
+		Where("id = ? AND status = ? AND expires_at <= ?", id, models.OutboxDead, now).Count(&expired).Error; err != nil {
+		return 0, err
+	}
+	if expired > 0 {
+		return 0, ErrEmailExpired
+	}
+	return 0, nil
+}
+
+// GetOutboxStats counts queued, sent and dead-lettered messages
+func GetOutboxStats() (*OutboxStats, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	if err := config.DB.Model(&models.EmailOutbox{}).Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	stats := &OutboxStats{}
+	for _, row := range rows {
+		switch row.Status {
+		case models.OutboxPending:
+			stats.Pending = row.Count
+		case models.OutboxSending:
+			stats.Sending = row.Count
+		case models.OutboxSent:
+			stats.Sent = row.Count
+		case models.OutboxDead:
+			stats.Dead = row.Count
+		}
+	}
+
+	if err := config.DB.Model(&models.EmailOutbox{}).
+		Where("status = ? AND attempts > 0", models.OutboxPending).Count(&stats.Retrying).Error; err != nil {
+		return nil, err
+	}
+
+	var oldest models.EmailOutbox
+	err := config.DB.Where("status IN ?", []string{models.OutboxPending, models.OutboxSending}).Order("created_at").First(&oldest).Error
+	if err == nil {
+		stats.OldestPendingAt = &oldest.CreatedAt
+	} else if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// ListOutboxEmails retrieves one page of messages with the given status, newest first, with the total number of matches
+func ListOutboxEmails(status string, offset, limit int) ([]models.EmailOutbox, int64, error) {
+	query := config.DB.Model(&models.EmailOutbox{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var messages []models.EmailOutbox
+	err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&messages).Error
+	return messages, total, err
+}
+
+// DeleteSentEmailsBefore removes delivery records older than the cutoff
+func DeleteSentEmailsBefore(cutoff time.Time) error {
+	return config.DB.Where("status = ? AND sent_at < ?", models.OutboxSent, cutoff).Delete(&models.EmailOutbox{}).Error
+}
+
+// DeleteDeadEmailsBefore removes dead-lettered messages last touched before the cutoff
+func DeleteDeadEmailsBefore(cutoff time.Time) error {
+	return config.DB.Where("status = ? AND updated_at < ?", models.OutboxDead, cutoff).Delete(&models.EmailOutbox{}).Error
+}
+
+// ClearExpiredDeadEmailPayloads drops the content of dead-lettered messages whose link has expired,
+// since they can no longer be requeued and the content may hold credentials
+func ClearExpiredDeadEmailPayloads() error {
+	return config.DB.Model(&models.EmailOutbox{}).
+		Where("status = ? AND expires_at <= now() AND payload <> ''", models.OutboxDead).
+		Update("payload", "").Error
+}
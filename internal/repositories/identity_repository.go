@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/models"
+	"gorm.io/gorm"
+)
+
+// GetUserIdentity retrieves a linked identity by provider and subject
+func GetUserIdentity(provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	if err := config.DB.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// LinkUserIdentity links an external identity to an existing user
+func LinkUserIdentity(userID uint, identity *models.UserIdentity) error {
+	identity.UserID = userID
+	return config.DB.Create(identity).Error
+}
+
+// CreateUserWithIdentity creates a new user and links the external identity in one transaction
+func CreateUserWithIdentity(user *models.User, identity *models.UserIdentity) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+}
+
+// UsernameExists reports whether a username is already taken
+func UsernameExists(username string) bool {
+	var count int64
+	config.DB.Model(&models.User{}).Unscoped().Where("username = ?", username).Count(&count)
+	return count > 0
+}
+
+// EmailExists reports whether any account, including a deleted one, uses the address in any letter case
+func EmailExists(email string) bool {
+	var count int64
+	config.DB.Model(&models.User{}).Unscoped().Where("LOWER(email) = LOWER(?)", email).Count(&count)
+	return count > 0
+}
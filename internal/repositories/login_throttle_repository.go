@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/models"
+)
+
+// GetLoginThrottles retrieves the throttle rows for an account key and an IP
+func GetLoginThrottles(accountKey, ip string) ([]models.LoginThrottle, error) {
+	var throttles []models.LoginThrottle
+	result := config.DB.Where("(scope = ? AND key = ?) OR (scope = ? AND key = ?)",
+		models.ThrottleScopeAccount, accountKey, models.ThrottleScopeIP, ip).Find(&throttles)
+	return throttles, result.Error
+}
+
+// RecordLoginFailure atomically counts a failed login and returns the updated row.
+// Failures older than the window are forgotten before counting.
+func RecordLoginFailure(scope, key string, window time.Duration) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	result := config.DB.Raw(`
+		INSERT INTO login_throttles (scope, key, failures, last_failure_at)
+		VALUES (?, ?, 1, now())
+		ON CONFLICT (scope, key) DO UPDATE SET
+			failures = CASE WHEN login_throttles.last_failure_at < ? THEN 1 ELSE login_throttles.failures + 1 END,
+			last_failure_at = now()
+		RETURNING *`, scope, key, time.Now().Add(-window)).Scan(&throttle)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &throttle, nil
+}
+
+// LockLoginThrottle locks a throttled account or IP until the given time
+func LockLoginThrottle(id uint, until time.Time) error {
+	return config.DB.Model(&models.LoginThrottle{}).Where("id = ?", id).Update("locked_until", until).Error
+}
+
+// ClearLoginThrottle forgets failed logins for an account or IP
+func ClearLoginThrottle(scope, key string) error {
+	return config.DB.Where("scope = ? AND key = ?", scope, key).Delete(&models.LoginThrottle{}).Error
+}
@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreateNotification adds an entry to a user's notification center
+func CreateNotification(notification *models.Notification) error {
+	return config.DB.Create(notification).Error
+}
+
+// ListNotifications retrieves one page of a user's notifications, newest first, with the total number of matches
+func ListNotifications(userID uint, unreadOnly bool, offset, limit int) ([]models.Notification, int64, error) {
+	query := config.DB.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var notifications []models.Notification
+	err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&notifications).Error
+	return notifications, total, err
+}
+
+// CountUnreadNotifications counts a user's unread notifications
+func CountUnreadNotifications(userID uint) (int64, error) {
+	var count int64
+	err := config.DB.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+// MarkNotificationRead marks one of the user's notifications read, returning gorm.ErrRecordNotFound
+// if the user has no such notification
+func MarkNotificationRead(userID, notificationID uint) error {
+	result := config.DB.Model(&models.Notification{}).
+		Where("id = ? AND user_id = ?", notificationID, userID).
+		Update("read_at", gorm.Expr("COALESCE(read_at, ?)", time.Now()))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// MarkAllNotificationsRead marks every unread notification of the user read and returns how many changed
+func MarkAllNotificationsRead(userID uint) (int64, error) {
+	result := config.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// GetNotificationPreference returns the user's preference for an event type, or the default if they have not set one
+func GetNotificationPreference(userID uint, eventType string) (models.NotificationPreference, error) {
+	var preference models.NotificationPreference
+	err := config.DB.Where("user_id = ? AND event_type = ?", userID, eventType).First(&preference).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultNotificationPreference(userID, eventType), nil
+	}
+	return preference, err
+}
+
+// GetNotificationPreferences returns the user's preference for every event type, filling in defaults
+func GetNotificationPreferences(userID uint) ([]models.NotificationPreference, error) {
+	var saved []models.NotificationPreference
+	if err := config.DB.Where("user_id = ?", userID).Find(&saved).Error; err != nil {
+		return nil, err
+	}
+	byType := make(map[string]models.NotificationPreference, len(saved))
+	for _, preference := range saved {
+		byType[preference.EventType] = preference
+	}
+
+	preferences := make([]models.NotificationPreference, 0, len(models.NotificationTypes))
+	for _, eventType := range models.NotificationTypes {
+		preference, ok := byType[eventType]
+		if !ok {
+			preference = models.DefaultNotificationPreference(userID, eventType)
+		}
+		preferences = append(preferences, preference)
+	}
+	return preferences, nil
+}
+
+// SaveNotificationPreferences creates or replaces the given preferences in one statement
+func SaveNotificationPreferences(preferences []models.NotificationPreference) error {
+	if len(preferences) == 0 {
+		return nil
+	}
+	return config.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "event_type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"in_app", "email", "updated_at"}),
+	}).Create(&preferences).Error
+}
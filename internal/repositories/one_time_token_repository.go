@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/models"
+	"gorm.io/gorm"
+)
+
+// CreateOneTimeToken stores a new token, invalidating the user's outstanding tokens for the same purpose
+func CreateOneTimeToken(token *models.OneTimeToken) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.OneTimeToken{}).
+			Where("user_id = ? AND purpose = ? AND consumed_at IS NULL", token.UserID, token.Purpose).
+			Update("consumed_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+// ConsumeOneTimeToken atomically marks a token as used and returns it.
+// It fails if the token is unknown, already used, expired or issued for another purpose.
+func ConsumeOneTimeToken(tokenHash, purpose string) (*models.OneTimeToken, error) {
+	var token models.OneTimeToken
+	result := config.DB.Raw(`
+		UPDATE one_time_tokens SET consumed_at = now()
+		WHERE token_hash = ? AND purpose = ? AND binding_hash IS NULL AND consumed_at IS NULL AND expires_at > now()
+		RETURNING *`, tokenHash, purpose).Scan(&token)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &token, nil
+}
+
+// ConsumeBoundOneTimeToken is ConsumeOneTimeToken for tokens bound to a browser secret.
+// The token is only consumed when the binding matches, so a forwarded link cannot burn it.
+func ConsumeBoundOneTimeToken(tokenHash, purpose, bindingHash string) (*models.OneTimeToken, error) {
+	var token models.OneTimeToken
+	result := config.DB.Raw(`
+		UPDATE one_time_tokens SET consumed_at = now()
+		WHERE token_hash = ? AND purpose = ? AND binding_hash = ? AND consumed_at IS NULL AND expires_at > now()
+		RETURNING *`, tokenHash, purpose, bindingHash).Scan(&token)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &token, nil
+}
+
+// GetActiveOneTimeToken retrieves a token that could still be consumed for the purpose, without consuming it
+func GetActiveOneTimeToken(tokenHash, purpose string) (*models.OneTimeToken, error) {
+	var token models.OneTimeToken
+	result := config.DB.Where("token_hash = ? AND purpose = ? AND consumed_at IS NULL AND expires_at > ?", tokenHash, purpose, time.Now()).First(&token)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &token, nil
+}
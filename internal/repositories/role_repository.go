@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/models"
+)
+
+// GetRoleByName retrieves a role and its permissions by name
+func GetRoleByName(name string) (*models.Role, error) {
+	var role models.Role
+	if err := config.DB.Preload("Permissions").Where("name = ?", name).First(&role).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// GetAllRoles retrieves every role with its permissions
+func GetAllRoles() ([]models.Role, error) {
+	var roles []models.Role
+	if err := config.DB.Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// GetAllPermissions retrieves every known permission
+func GetAllPermissions() ([]models.Permission, error) {
+	var permissions []models.Permission
+	if err := config.DB.Order("name").Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// GetPermissionsByNames retrieves the permissions matching the given names
+func GetPermissionsByNames(names []string) ([]models.Permission, error) {
+	var permissions []models.Permission
+	if len(names) == 0 {
+		return permissions, nil
+	}
+	if err := config.DB.Where("name IN ?", names).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// CreateRole inserts a new role along with its permission grants
+func CreateRole(role *models.Role) error {
+	return config.DB.Create(role).Error
+}
+
+// ReplaceRolePermissions replaces the full permission set of a role
+func ReplaceRolePermissions(role *models.Role, permissions []models.Permission) error {
+	return config.DB.Model(role).Association("Permissions").Replace(permissions)
+}
@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/models"
+	"gorm.io/gorm"
+)
+
+// CreateTask stores a task together with its creator's owner grant
+func CreateTask(task *models.Task, ownerID uint) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(task).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.ResourceGrant{
+			ResourceType: models.ResourceTask,
+			ResourceID:   task.ID,
+			UserID:       ownerID,
+			Access:       models.AccessOwner,
+			GrantedBy:    &ownerID,
+		}).Error
+	})
+}
+
+// GetTasks retrieves every task
+func GetTasks() ([]models.Task, error) {
+	var tasks []models.Task
+	err := config.DB.Order("id").Find(&tasks).Error
+	return tasks, err
+}
+
+// GetTasksForUser retrieves the tasks a user holds any grant on, in a single query
+func GetTasksForUser(userID uint) ([]models.Task, error) {
+	var tasks []models.Task
+	err := config.DB.
+		Joins("JOIN resource_grants ON resource_grants.resource_type = ? AND resource_grants.resource_id = tasks.id AND resource_grants.user_id = ?", models.ResourceTask, userID).
+		Order("tasks.id").Find(&tasks).Error
+	return tasks, err
+}
+
+// GetOpenTasksDueBefore retrieves up to limit unfinished tasks due before the given time that the user
+// owns or may edit, earliest due first
+func GetOpenTasksDueBefore(userID uint, before time.Time, limit int) ([]models.Task, error) {
+	var tasks []models.Task
+	err := config.DB.
+		Joins("JOIN resource_grants ON resource_grants.resource_type = ? AND resource_grants.resource_id = tasks.id AND resource_grants.user_id = ?", models.ResourceTask, userID).
+		Where("resource_grants.access IN ? AND tasks.status = ? AND tasks.due_at < ?", []string{models.AccessOwner, models.AccessEditor}, false, before).
+		Order("tasks.due_at, tasks.id").Limit(limit).Find(&tasks).Error
+	return tasks, err
+}
+
+// TaskExists reports whether a task exists
+func TaskExists(id uint) (bool, error) {
+	var count int64
+	err := config.DB.Model(&models.Task{}).Where("id = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
+// FinishTask marks a task done, returning gorm.ErrRecordNotFound if there is no such task
+func FinishTask(id uint) (*models.Task, error) {
+	var task models.Task
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Task{}).Where("id = ?", id).Update("status", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.First(&task, id).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// DeleteTask removes a task and every grant on it, returning gorm.ErrRecordNotFound if there is no such task
+func DeleteTask(id uint) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.Task{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("resource_type = ? AND resource_id = ?", models.ResourceTask, id).Delete(&models.ResourceGrant{}).Error
+	})
+}
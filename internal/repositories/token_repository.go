@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/models"
+)
+
+// CreatePersonalAccessToken inserts a new personal access token
+func CreatePersonalAccessToken(token *models.PersonalAccessToken) error {
+	return config.DB.Create(token).Error
+}
+
+// GetPersonalAccessTokenByHash retrieves a token by the hash of its plain value
+func GetPersonalAccessTokenByHash(tokenHash string) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	if err := config.DB.Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// GetPersonalAccessTokensByUser retrieves every token belonging to a user
+func GetPersonalAccessTokensByUser(userID uint) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	result := config.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens)
+	return tokens, result.Error
+}
+
+// RevokePersonalAccessToken marks one of a user's tokens as revoked.
+// It returns the number of tokens affected so callers can detect unknown IDs.
+func RevokePersonalAccessToken(userID, tokenID uint) (int64, error) {
+	result := config.DB.Model(&models.PersonalAccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", tokenID, userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// TouchPersonalAccessToken records when a token was last used
+func TouchPersonalAccessToken(tokenID uint) error {
+	return config.DB.Model(&models.PersonalAccessToken{}).Where("id = ?", tokenID).Update("last_used_at", time.Now()).Error
+}
@@ -1,20 +1,51 @@
 package repositories
 
 import (
+	"errors"
+	"strings"
+	"time"
+
 	"github.com/wanloq/taskinator/internal/config"
 	"github.com/wanloq/taskinator/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Deleted-user filters for ListUsers
+const (
+	DeletedExclude = ""
+	DeletedInclude = "include"
+	DeletedOnly    = "only"
 )
 
+// UserFilter narrows a user listing; zero values match everything
+type UserFilter struct {
+	Role     string
+	Verified *bool
+	// Status matches the effective status, so lapsed suspensions count as active
+	Status        string
+	Deleted       string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// Query matches a substring of the username or email
+	Query  string
+	Offset int
+	Limit  int
+}
+
+// ErrStaleUpdate is returned when a conditional update finds the row changed since it was read
+var ErrStaleUpdate = errors.New("user was modified since it was read")
+
 // CreateUser inserts a new user into the database
 func CreateUser(user *models.User) error {
 	result := config.DB.Create(user)
 	return result.Error
 }
 
-// GetUserByEmail retrieves a user by email
+// GetUserByEmail retrieves a user by email, ignoring case
 func GetUserByEmail(email string) (*models.User, error) {
 	var user models.User
-	result := config.DB.Where("email = ?", email).First(&user)
+	result := config.DB.Where("LOWER(email) = LOWER(?)", email).First(&user)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -30,14 +61,79 @@ func GetUserByID(userID uint) (*models.User, error) {
 	return &user, nil
 }
 
-// UpdateUser updates an existing user in the database
-func UpdateUser(user *models.User) error {
-	return config.DB.Save(user).Error
+// GetUserByIDUnscoped retrieves a user by ID, including deleted users
+func GetUserByIDUnscoped(userID uint) (*models.User, error) {
+	var user models.User
+	if err := config.DB.Unscoped().First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListUsers retrieves one page of users matching the filter, ordered by ID, with the total number of matches
+func ListUsers(filter UserFilter) ([]models.User, int64, error) {
+	query := filteredUsers(filter)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var users []models.User
+	err := query.Order("id").Offset(filter.Offset).Limit(filter.Limit).Find(&users).Error
+	return users, total, err
 }
 
-// UpdateUser updates an existing user in the database
-func DeleteUser(user *models.User) error {
-	return config.DB.Delete(user).Error
+// EachUserBatch calls fn with successive batches of users matching the filter, ordered by ID.
+// Offset and Limit are ignored; iteration stops at the first error.
+func EachUserBatch(filter UserFilter, batchSize int, fn func([]models.User) error) error {
+	var batch []models.User
+	return filteredUsers(filter).Order("id").FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+		return fn(batch)
+	}).Error
+}
+
+// filteredUsers builds the query selecting users that match the filter
+func filteredUsers(filter UserFilter) *gorm.DB {
+	query := config.DB.Model(&models.User{})
+	switch filter.Deleted {
+	case DeletedInclude:
+		query = query.Unscoped()
+	case DeletedOnly:
+		query = query.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.Verified != nil {
+		query = query.Where("is_verified = ?", *filter.Verified)
+	}
+	switch filter.Status {
+	case "":
+	case models.UserStatusSuspended, models.UserStatusLocked:
+		query = query.Where("status = ? AND (status_until IS NULL OR status_until > now())", filter.Status)
+	case models.UserStatusActive:
+		query = query.Where("(status = ? OR (status IN ? AND status_until <= now()))",
+			models.UserStatusActive, []string{models.UserStatusSuspended, models.UserStatusLocked})
+	default:
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *filter.CreatedBefore)
+	}
+	if filter.Query != "" {
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(filter.Query) + "%"
+		query = query.Where("(username ILIKE ? OR email ILIKE ?)", pattern, pattern)
+	}
+	return query
+}
+
+// SetUserStatus changes a user's account status, its reason and when it ends
+func SetUserStatus(userID uint, status, reason string, until *time.Time) error {
+	return config.DB.Model(&models.User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"status": status, "status_reason": reason, "status_until": until}).Error
 }
 
 // UpdateUserPassword updates a user's password by email
@@ -57,3 +153,64 @@ func VerifyUserEmail(email string) error {
 	}
 	return nil
 }
+
+// UpdateUserRole sets the role of a user by ID
+func UpdateUserRole(userID uint, role string) error {
+	return config.DB.Model(&models.User{}).Where("id = ?", userID).Update("role", role).Error
+}
+
+// UpdateUserFields updates only the given columns of a user.
+// When unmodifiedSince is set the update only applies if the user's updated_at still matches it.
+func UpdateUserFields(userID uint, fields map[string]interface{}, unmodifiedSince *time.Time) error {
+	query := config.DB.Model(&models.User{}).Where("id = ?", userID)
+	if unmodifiedSince != nil {
+		query = query.Where("ROUND(EXTRACT(EPOCH FROM updated_at) * 1000000) = ?", unmodifiedSince.UnixMicro())
+	}
+	result := query.Updates(fields)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		if unmodifiedSince != nil {
+			return ErrStaleUpdate
+		}
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// SaveUserAvatar stores or replaces a user's avatar and records when it changed
+func SaveUserAvatar(avatar *models.UserAvatar) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"content_type", "data", "updated_at"}),
+		}).Create(avatar).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", avatar.UserID).Update("avatar_updated_at", avatar.UpdatedAt).Error
+	})
+}
+
+// GetUserAvatar retrieves a user's avatar
+func GetUserAvatar(userID uint) (*models.UserAvatar, error) {
+	var avatar models.UserAvatar
+	if err := config.DB.First(&avatar, "user_id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	return &avatar, nil
+}
+
+// DeleteUserAvatar removes a user's avatar, returning the number of avatars deleted
+func DeleteUserAvatar(userID uint) (int64, error) {
+	var deleted int64
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ?", userID).Delete(&models.UserAvatar{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		return tx.Model(&models.User{}).Where("id = ?", userID).Update("avatar_updated_at", nil).Error
+	})
+	return deleted, err
+}
@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/models"
+	"gorm.io/gorm"
+)
+
+// CreateWebAuthnCredential inserts a newly registered passkey
+func CreateWebAuthnCredential(credential *models.WebAuthnCredential) error {
+	return config.DB.Create(credential).Error
+}
+
+// GetWebAuthnCredentialsByUser retrieves every passkey registered by a user
+func GetWebAuthnCredentialsByUser(userID uint) ([]models.WebAuthnCredential, error) {
+	var credentials []models.WebAuthnCredential
+	result := config.DB.Where("user_id = ?", userID).Order("created_at").Find(&credentials)
+	return credentials, result.Error
+}
+
+// GetWebAuthnCredentialByCredentialID retrieves a passkey by its authenticator-assigned ID
+func GetWebAuthnCredentialByCredentialID(credentialID []byte) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	if err := config.DB.Where("credential_id = ?", credentialID).First(&credential).Error; err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// UpdateWebAuthnCredentialUsage records a login's signature counter, flags and clone warning
+func UpdateWebAuthnCredentialUsage(id uint, signCount uint32, flags uint8, cloneWarning bool) error {
+	return config.DB.Model(&models.WebAuthnCredential{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"sign_count":    signCount,
+		"flags":         flags,
+		"clone_warning": cloneWarning,
+		"last_used_at":  time.Now(),
+	}).Error
+}
+
+// DeleteWebAuthnCredential removes one of a user's passkeys, returning the number of rows deleted
+func DeleteWebAuthnCredential(userID, id uint) (int64, error) {
+	result := config.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&models.WebAuthnCredential{})
+	return result.RowsAffected, result.Error
+}
+
+// CreateWebAuthnSession stores the state of a ceremony in progress
+func CreateWebAuthnSession(session *models.WebAuthnSession) error {
+	return config.DB.Create(session).Error
+}
+
+// ConsumeWebAuthnSession atomically deletes and returns an unexpired ceremony session for the purpose
+func ConsumeWebAuthnSession(sessionKeyHash, purpose string) (*models.WebAuthnSession, error) {
+	var session models.WebAuthnSession
+	result := config.DB.Raw(`
+		DELETE FROM webauthn_sessions
+		WHERE session_key_hash = ? AND purpose = ? AND expires_at > now()
+		RETURNING *`, sessionKeyHash, purpose).Scan(&session)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &session, nil
+}
+
+// DeleteExpiredWebAuthnSessions removes ceremonies that were started but never finished before now,
+// returning the number of rows deleted
+func DeleteExpiredWebAuthnSessions(now time.Time) (int64, error) {
+	result := config.DB.Where("expires_at <= ?", now).Delete(&models.WebAuthnSession{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,42 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/wanloq/taskinator/internal/controllers"
+	"github.com/wanloq/taskinator/internal/middleware"
+	"github.com/wanloq/taskinator/internal/models"
+)
+
+// SetupAdminRoutes defines administration routes
+func SetupAdminRoutes(app *fiber.App) {
+	adminGroup := app.Group("/api/admin", middleware.JWTMiddleware)
+
+	// Roles and permissions
+	adminGroup.Get("/roles", middleware.RequirePermission(models.PermRolesManage), controllers.ListRoles)
+	adminGroup.Post("/roles", middleware.RequirePermission(models.PermRolesManage), controllers.CreateRole)
+	adminGroup.Put("/roles/:name/permissions", middleware.RequirePermission(models.PermRolesManage), controllers.UpdateRolePermissions)
+	adminGroup.Get("/permissions", middleware.RequirePermission(models.PermRolesManage), controllers.ListPermissions)
+	adminGroup.Put("/users/:id/role", middleware.RequirePermission(models.PermRolesManage), controllers.AssignUserRole)
+
+	// Account management
+	adminGroup.Get("/users", middleware.RequirePermission(models.PermUsersRead), controllers.ListUsers)
+	adminGroup.Get("/users/export", middleware.RequirePermission(models.PermUsersRead), controllers.ExportUsers)
+	adminGroup.Post("/users/import", middleware.RequirePermission(models.PermUsersWrite), controllers.ImportUsers)
+	adminGroup.Get("/users/:id", middleware.RequirePermission(models.PermUsersRead), controllers.GetUser)
+	adminGroup.Delete("/users/:id", middleware.RequirePermission(models.PermUsersDelete), controllers.DeleteUserProfile)
+	adminGroup.Post("/users/:id/verify", middleware.RequirePermission(models.PermUsersWrite), controllers.VerifyUser)
+	adminGroup.Post("/users/:id/suspend", middleware.RequirePermission(models.PermUsersWrite), controllers.SuspendUser)
+	adminGroup.Post("/users/:id/lock", middleware.RequirePermission(models.PermUsersWrite), controllers.LockUser)
+	adminGroup.Post("/users/:id/reinstate", middleware.RequirePermission(models.PermUsersWrite), controllers.ReinstateUser)
+	adminGroup.Post("/users/:id/password-reset", middleware.RequirePermission(models.PermUsersWrite), controllers.TriggerPasswordReset)
+	adminGroup.Post("/users/:id/impersonate", middleware.RequirePermission(models.PermUsersImpersonate), controllers.ImpersonateUser)
+	adminGroup.Post("/users/:id/unlock", middleware.RequirePermission(models.PermUsersWrite), controllers.UnlockUserAccount)
+
+	// Audit
+	adminGroup.Get("/audit-logs", middleware.RequirePermission(models.PermUsersRead), controllers.ListAuditLogs)
+
+	// Email delivery
+	adminGroup.Get("/email/outbox", middleware.RequirePermission(models.PermUsersRead), controllers.ListOutboxEmails)
+	adminGroup.Get("/email/outbox/stats", middleware.RequirePermission(models.PermUsersRead), controllers.GetOutboxStats)
+	adminGroup.Post("/email/outbox/:id/retry", middleware.RequirePermission(models.PermUsersWrite), controllers.RetryOutboxEmail)
+}
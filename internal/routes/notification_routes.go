@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/wanloq/taskinator/internal/controllers"
+	"github.com/wanloq/taskinator/internal/middleware"
+	"github.com/wanloq/taskinator/internal/models"
+)
+
+// SetupNotificationRoutes defines the notification center and preference routes
+func SetupNotificationRoutes(app *fiber.App) {
+	notificationGroup := app.Group("/api/notifications", middleware.JWTMiddleware)
+	notificationsRead := middleware.RequireTokenScope(models.PermNotificationsRead)
+	notificationsWrite := middleware.RequireTokenScope(models.PermNotificationsWrite)
+
+	notificationGroup.Get("/", notificationsRead, controllers.ListNotifications)
+	notificationGroup.Get("/unread-count", notificationsRead, controllers.GetUnreadNotificationCount)
+	notificationGroup.Post("/read-all", notificationsWrite, controllers.MarkAllNotificationsRead)
+	notificationGroup.Post("/:id/read", notificationsWrite, controllers.MarkNotificationRead)
+	notificationGroup.Get("/preferences", notificationsRead, controllers.GetNotificationPreferences)
+	notificationGroup.Put("/preferences", notificationsWrite, controllers.UpdateNotificationPreferences)
+	notificationGroup.Get("/digest", notificationsRead, controllers.GetDigestSettings)
+	notificationGroup.Put("/digest", notificationsWrite, controllers.UpdateDigestSettings)
+}
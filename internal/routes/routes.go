@@ -2,6 +2,7 @@ package routes
 
 import (
 	"github.com/wanloq/taskinator/internal/controllers"
+	"github.com/wanloq/taskinator/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/swagger"
@@ -15,11 +16,24 @@ func SetupRoutes(app *fiber.App) {
 		return c.SendString("Welcome to Taskinator!")
 	})
 	app.Get("/swagger/*", swagger.HandlerDefault)
+
+	// Landing pages opened from emailed links
+	app.Get(utils.ResetPasswordPath, controllers.ResetPasswordPage)
+	app.Post(utils.ResetPasswordPath, controllers.SubmitResetPasswordPage)
+	app.Get(utils.AcceptInvitationPath, controllers.AcceptInvitationPage)
+	app.Post(utils.AcceptInvitationPath, controllers.SubmitAcceptInvitationPage)
+	app.Get(utils.VerifyEmailPath, controllers.VerifyEmailPage)
+	app.Get(utils.ConfirmEmailChangePath, controllers.ConfirmEmailChangePage)
+	app.Post(utils.ConfirmEmailChangePath, controllers.SubmitConfirmEmailChangePage)
+	app.Get(utils.RevertEmailChangePath, controllers.RevertEmailChangePage)
+	app.Post(utils.RevertEmailChangePath, controllers.SubmitRevertEmailChangePage)
+
 	api.Post("/register", controllers.RegisterUser)
 	api.Post("/login", controllers.LoginUser)
-
-	// Task routes
-	// app.Get("/all", GetAll)
-	// app.Post("/task", CreateTask)
-	// app.Patch("/task", FinishTask)
+	api.Post("/login/magic", controllers.RequestMagicLink)
+	api.Get("/login/magic/verify", controllers.VerifyMagicLink)
+	api.Post("/login/passkey/begin", controllers.BeginPasskeyLogin)
+	api.Post("/login/passkey/finish", controllers.FinishPasskeyLogin)
+	api.Get("/auth/oidc/:provider/login", controllers.OIDCLogin)
+	api.Get("/auth/oidc/:provider/callback", controllers.OIDCCallback)
 }
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/wanloq/taskinator/internal/controllers"
+	"github.com/wanloq/taskinator/internal/middleware"
+	"github.com/wanloq/taskinator/internal/models"
+)
+
+// SetupShareRoutes defines sharing routes for tasks. Projects get the same routes once they exist.
+func SetupShareRoutes(app *fiber.App) {
+	resources := map[string]string{
+		"tasks": models.ResourceTask,
+	}
+
+	for path, resourceType := range resources {
+		shareGroup := app.Group("/api/"+path+"/:id/shares", middleware.JWTMiddleware, middleware.RequireResourceAccess(resourceType, models.AccessOwner))
+
+		shareGroup.Get("/", controllers.ListResourceShares(resourceType))
+		shareGroup.Post("/", controllers.ShareResource(resourceType))
+		shareGroup.Delete("/:userId", controllers.RevokeResourceShare(resourceType))
+	}
+}
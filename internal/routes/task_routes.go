@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/wanloq/taskinator/internal/controllers"
+	"github.com/wanloq/taskinator/internal/middleware"
+	"github.com/wanloq/taskinator/internal/models"
+)
+
+// SetupTaskRoutes defines the task routes; access to each task is decided by its resource grants
+func SetupTaskRoutes(app *fiber.App) {
+	taskGroup := app.Group("/api/tasks", middleware.JWTMiddleware)
+
+	taskGroup.Get("/", middleware.RequirePermission(models.PermTasksRead), controllers.GetTasks)
+	taskGroup.Post("/", middleware.RequirePermission(models.PermTasksWrite), controllers.CreateTask)
+	taskGroup.Patch("/:id", middleware.RequireResourceAccess(models.ResourceTask, models.AccessEditor), controllers.FinishTask)
+	taskGroup.Delete("/:id", middleware.RequirePermission(models.PermTasksDelete), middleware.RequireResourceAccess(models.ResourceTask, models.AccessOwner), controllers.DeleteTask)
+}
@@ -4,19 +4,39 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/wanloq/taskinator/internal/controllers"
 	"github.com/wanloq/taskinator/internal/middleware"
+	"github.com/wanloq/taskinator/internal/models"
 )
 
 // SetupUserRoutes defines user-related routes
 func SetupUserRoutes(app *fiber.App) {
 	userGroup := app.Group("/user")
+	profileRead := middleware.RequireTokenScope(models.PermProfileRead)
+	profileWrite := middleware.RequireTokenScope(models.PermProfileWrite)
 
-	// Protected route (requires authentication)
-	userGroup.Get("/profile", controllers.GetUserProfile)
-	userGroup.Put("/update", controllers.UpdateUserProfile)
+	// Public routes
 	userGroup.Post("/password-reset/request", controllers.RequestPasswordReset)
 	userGroup.Post("/password-reset/confirm", controllers.PasswordReset)
 	userGroup.Post("/email/verify/request", controllers.RequestEmailVerification)
 	userGroup.Get("/email/verify", controllers.VerifyEmail)
-	// userGroup.Delete("/delete", controllers.DeleteUserProfile)
-	userGroup.Delete("/admin/delete-user/:id", middleware.RoleMiddleware("admin"), controllers.DeleteUserProfile)
+	userGroup.Post("/email/change/confirm", controllers.ConfirmEmailChange)
+	userGroup.Post("/email/change/revert", controllers.RevertEmailChange)
+	userGroup.Post("/invitation/accept", controllers.AcceptInvitation)
+
+	// Protected routes (requires authentication)
+	userGroup.Get("/profile", middleware.JWTMiddleware, profileRead, controllers.GetUserProfile)
+	userGroup.Patch("/profile", middleware.JWTMiddleware, profileWrite, controllers.PatchUserProfile)
+	userGroup.Put("/update", middleware.JWTMiddleware, profileWrite, controllers.UpdateUserProfile)
+	userGroup.Put("/avatar", middleware.JWTMiddleware, profileWrite, controllers.UploadAvatar)
+	userGroup.Get("/avatar", middleware.JWTMiddleware, profileRead, controllers.GetAvatar)
+	userGroup.Delete("/avatar", middleware.JWTMiddleware, profileWrite, controllers.DeleteAvatar)
+	userGroup.Post("/tokens", middleware.JWTMiddleware, controllers.CreatePersonalAccessToken)
+	userGroup.Get("/tokens", middleware.JWTMiddleware, profileRead, controllers.ListPersonalAccessTokens)
+	userGroup.Delete("/tokens/:id", middleware.JWTMiddleware, controllers.RevokePersonalAccessToken)
+	userGroup.Post("/passkeys/register/begin", middleware.JWTMiddleware, controllers.BeginPasskeyRegistration)
+	userGroup.Post("/passkeys/register/finish", middleware.JWTMiddleware, controllers.FinishPasskeyRegistration)
+	userGroup.Get("/passkeys", middleware.JWTMiddleware, profileRead, controllers.ListPasskeys)
+	userGroup.Delete("/passkeys/:id", middleware.JWTMiddleware, controllers.DeletePasskey)
+	userGroup.Delete("/me", middleware.JWTMiddleware, controllers.DeleteOwnAccount)
+	userGroup.Get("/me/export", middleware.JWTMiddleware, controllers.ExportAccountData)
+	userGroup.Delete("/admin/delete-user/:id", middleware.JWTMiddleware, middleware.RequirePermission(models.PermUsersDelete), controllers.DeleteUserProfile)
 }
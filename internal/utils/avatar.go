@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Avatar limits
+const (
+	AvatarMaxUploadBytes = 2 << 20
+	AvatarSize           = 256
+	avatarMaxPixels      = 40_000_000
+)
+
+// AvatarContentType is the format stored avatars are re-encoded to
+const AvatarContentType = "image/png"
+
+// ErrInvalidAvatar is returned when an upload is not a supported image or is too large to process
+var ErrInvalidAvatar = errors.New("avatar must be a JPEG, PNG, GIF or WebP image")
+
+// ResizeAvatar decodes an uploaded image, crops it to a centred square and
+// re-encodes it as an AvatarSize x AvatarSize PNG. Re-encoding also strips any metadata.
+func ResizeAvatar(data []byte) ([]byte, error) {
+	// Check the dimensions before decoding so a small file cannot expand into a huge bitmap
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > avatarMaxPixels {
+		return nil, ErrInvalidAvatar
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidAvatar
+	}
+
+	bounds := src.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	x0 := bounds.Min.X + (bounds.Dx()-side)/2
+	y0 := bounds.Min.Y + (bounds.Dy()-side)/2
+	crop := image.Rect(x0, y0, x0+side, y0+side)
+
+	dst := image.NewRGBA(image.Rect(0, 0, AvatarSize, AvatarSize))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+
+	var out bytes.Buffer
+	if err := png.Encode(&out, dst); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/models"
+)
+
+// DigestMaxItems bounds how many tasks, and separately how many notifications, one digest lists
+const DigestMaxItems = 50
+
+// SendDigestEmail summarises, as of now, the user's overdue tasks and tasks due today, then their
+// notifications since the given time: security alerts and other recent activity. dueTasks are the
+// open tasks due before the end of the user's day; more is the number of further notifications that did not fit.
+func SendDigestEmail(user *models.User, since, now time.Time, dueTasks []models.Task, notifications []models.Notification, more int) error {
+	email := newEmailContent(user, "digest."+user.DigestFrequency+".title")
+	email.Paragraphs = []string{email.t("digest.intro", "time", formatUserTime(user, since))}
+
+	sections := map[string]*emailSection{}
+	order := []string{"digest.overdue", "digest.due_today", "digest.security", "digest.activity"}
+	add := func(key, item string) {
+		if sections[key] == nil {
+			sections[key] = &emailSection{Heading: email.t(key)}
+		}
+		sections[key].Items = append(sections[key].Items, item)
+	}
+	for _, task := range dueTasks {
+		key := "digest.due_today"
+		if task.DueAt.Before(now) {
+			key = "digest.overdue"
+		}
+		add(key, email.t("digest.task", "name", task.Name, "time", formatUserTime(user, *task.DueAt)))
+	}
+	for _, notification := range notifications {
+		key := "digest.activity"
+		switch notification.Type {
+		case models.NotificationDueReminder:
+			key = "digest.due_today"
+		case models.NotificationSecurity:
+			key = "digest.security"
+		}
+		add(key, digestItem(user, notification))
+	}
+	for _, key := range order {
+		if section := sections[key]; section != nil {
+			email.Sections = append(email.Sections, *section)
+		}
+	}
+
+	if more > 0 {
+		email.Notes = append(email.Notes, email.t("digest.more", "count", strconv.Itoa(more)))
+	}
+	if config.URLs.FrontendURL != "" {
+		email.Action = &emailAction{URL: PageURL("/notifications", nil), Label: email.t("digest.action")}
+	}
+	email.Notes = append(email.Notes, email.t("digest.manage"))
+
+	return sendEmail(user, user.Email, email)
+}
+
+// digestItem describes one notification in a digest by the first paragraph of its body
+func digestItem(user *models.User, notification models.Notification) string {
+	text := notification.Title
+	if body, _, _ := strings.Cut(notification.Body, "\n\n"); body != "" {
+		text = body
+	}
+	return formatUserTime(user, notification.CreatedAt) + " - " + text
+}
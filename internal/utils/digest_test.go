@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wanloq/taskinator/internal/models"
+)
+
+func TestSendDigestEmailListsDueTasksFirst(t *testing.T) {
+	mailer := useMemoryMailer(t)
+	user := &models.User{Username: "jane", Email: "jane@example.com", DigestFrequency: models.DigestDaily}
+
+	now := time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC)
+	yesterday, tonight := now.Add(-24*time.Hour), now.Add(10*time.Hour)
+	dueTasks := []models.Task{
+		{ID: 1, Name: "File taxes", DueAt: &yesterday},
+		{ID: 2, Name: "Water plants", DueAt: &tonight},
+	}
+	notifications := []models.Notification{
+		{Type: models.NotificationSecurity, Title: "Account reinstated", CreatedAt: now.Add(-time.Hour)},
+		{Type: models.NotificationAssignment, Title: "Task shared", CreatedAt: now.Add(-2 * time.Hour)},
+	}
+	if err := SendDigestEmail(user, now.Add(-24*time.Hour), now, dueTasks, notifications, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := mailer.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(messages))
+	}
+	body := messages[0].TextBody
+	previous := -1
+	for _, want := range []string{
+		"Overdue tasks", "File taxes (due 2026-03-09 08:00 UTC)",
+		"Due today", "Water plants (due 2026-03-10 18:00 UTC)",
+		"Security alerts", "Account reinstated",
+		"Recent activity", "Task shared",
+	} {
+		i := strings.Index(body, want)
+		if i < 0 {
+			t.Fatalf("digest is missing %q:\n%s", want, body)
+		}
+		if i < previous {
+			t.Fatalf("%q is out of order:\n%s", want, body)
+		}
+		previous = i
+	}
+}
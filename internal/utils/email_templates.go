@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	htmltemplate "html/template"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/wanloq/taskinator/internal/models"
+)
+
+// DefaultLocale is used when a user's locale has no translation catalog
+const DefaultLocale = "en"
+
+// emailTimeLayout formats times shown in email in the recipient's timezone; it is numeric so it reads the same in every locale
+const emailTimeLayout = "2006-01-02 15:04 MST"
+
+//go:embed templates/email/*.tmpl templates/pages/*.tmpl
+var templateFS embed.FS
+
+//go:embed templates/locales/*.json
+var localeFS embed.FS
+
+var (
+	htmlEmailLayout   = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/email/layout.html.tmpl"))
+	textEmailLayout   = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/email/layout.txt.tmpl"))
+	landingPageLayout = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/pages/page.html.tmpl"))
+	// catalogs maps a lower-case locale tag to its messages
+	catalogs = loadCatalogs()
+)
+
+// emailAction is the link an email asks the reader to follow
+type emailAction struct {
+	URL   string
+	Label string
+}
+
+// emailSection is a headed list of items, such as one group of a digest
+type emailSection struct {
+	Heading string
+	Items   []string
+}
+
+// emailContent is the localized content of one email, rendered into both layouts
+type emailContent struct {
+	Locale string
+	// Title names the email; the subject adds the product name, and notifications reuse it on its own
+	Title          string
+	Subject        string
+	Greeting       string
+	Paragraphs     []string
+	Sections       []emailSection
+	Action         *emailAction
+	Notes          []string
+	ButtonFallback string
+	Signature      string
+	// expiresAt is when the email's link stops working; a copy still queued then is not delivered
+	expiresAt time.Time
+}
+
+// loadCatalogs reads the embedded translation catalogs, panicking at startup on a malformed one
+func loadCatalogs() map[string]map[string]string {
+	files, err := localeFS.ReadDir("templates/locales")
+	if err != nil {
+		panic(err)
+	}
+	loaded := make(map[string]map[string]string, len(files))
+	for _, file := range files {
+		data, err := localeFS.ReadFile(path.Join("templates/locales", file.Name()))
+		if err != nil {
+			panic(err)
+		}
+		messages := map[string]string{}
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic("invalid translation catalog " + file.Name() + ": " + err.Error())
+		}
+		loaded[strings.ToLower(strings.TrimSuffix(file.Name(), ".json"))] = messages
+	}
+	return loaded
+}
+
+// ResolveLocale returns the most specific available catalog for a BCP 47 tag,
+// so "es-MX" uses "es" and unknown languages use DefaultLocale
+func ResolveLocale(tag string) string {
+	tag = strings.ToLower(tag)
+	for tag != "" {
+		if _, ok := catalogs[tag]; ok {
+			return tag
+		}
+		i := strings.LastIndex(tag, "-")
+		if i < 0 {
+			break
+		}
+		tag = tag[:i]
+	}
+	return DefaultLocale
+}
+
+// Translate returns the message for key in locale, falling back to DefaultLocale and then the key.
+// args are placeholder/value pairs: Translate("en", "invitation.note", "days", "7") fills {days}.
+func Translate(locale, key string, args ...string) string {
+	message, ok := catalogs[ResolveLocale(locale)][key]
+	if !ok {
+		if message, ok = catalogs[DefaultLocale][key]; !ok {
+			message = key
+		}
+	}
+	if len(args) == 0 {
+		return message
+	}
+	pairs := make([]string, 0, len(args))
+	for i := 0; i+1 < len(args); i += 2 {
+		pairs = append(pairs, "{"+args[i]+"}", args[i+1])
+	}
+	return strings.NewReplacer(pairs...).Replace(message)
+}
+
+// newEmailContent starts the content of an email to user in their locale
+func newEmailContent(user *models.User, titleKey string) *emailContent {
+	locale := ResolveLocale(user.Locale)
+	title := Translate(locale, titleKey)
+	return &emailContent{
+		Locale:         locale,
+		Title:          title,
+		Subject:        Translate(locale, "common.subject", "title", title),
+		Greeting:       Translate(locale, "common.greeting", "name", recipientName(user)),
+		ButtonFallback: Translate(locale, "common.button_fallback"),
+		Signature:      Translate(locale, "common.signature"),
+	}
+}
+
+// t translates key in the email's locale
+func (e *emailContent) t(key string, args ...string) string {
+	return Translate(e.Locale, key, args...)
+}
+
+// render produces the plain-text and HTML bodies
+func (e *emailContent) render() (string, string, error) {
+	var text, html bytes.Buffer
+	if err := textEmailLayout.Execute(&text, e); err != nil {
+		return "", "", err
+	}
+	if err := htmlEmailLayout.Execute(&html, e); err != nil {
+		return "", "", err
+	}
+	return text.String(), html.String(), nil
+}
+
+// recipientName is how an email addresses the user
+func recipientName(user *models.User) string {
+	if user.DisplayName != "" {
+		return user.DisplayName
+	}
+	return user.Username
+}
+
+// formatUserTime formats t in the user's timezone, or UTC when it is unset or unknown
+func formatUserTime(user *models.User, t time.Time) string {
+	location := time.UTC
+	if user.Timezone != "" {
+		if loaded, err := time.LoadLocation(user.Timezone); err == nil {
+			location = loaded
+		}
+	}
+	return t.In(location).Format(emailTimeLayout)
+}
+
+// PageForm is the form that redeems a link's token. Password reset and invitation pages ask for a
+// new password; pages without a PasswordLabel only ask the user to confirm.
+type PageForm struct {
+	Action        string
+	Token         string
+	PasswordLabel string
+	ConfirmLabel  string
+	Submit        string
+}
+
+// PageContent is a minimal HTML page shown to users who follow an emailed link
+type PageContent struct {
+	Locale  string
+	Title   string
+	Message string
+	Error   bool
+	Form    *PageForm
+}
+
+// RenderPage renders a landing page
+func RenderPage(page PageContent) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := landingPageLayout.Execute(&buf, page); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// AvailableLocales lists the locales that have a translation catalog
+func AvailableLocales() []string {
+	locales := make([]string, 0, len(catalogs))
+	for locale := range catalogs {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
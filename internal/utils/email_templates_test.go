@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderPageForms(t *testing.T) {
+	passwordForm := &PageForm{Action: ResetPasswordPath, Token: "abc", PasswordLabel: "New password", ConfirmLabel: "Confirm", Submit: "Reset"}
+	confirmForm := &PageForm{Action: ConfirmEmailChangePath, Token: "abc", Submit: "Confirm email address"}
+
+	for _, tc := range []struct {
+		name          string
+		form          *PageForm
+		wantPasswords bool
+	}{
+		{"password form", passwordForm, true},
+		{"confirmation form", confirmForm, false},
+	} {
+		body, err := RenderPage(PageContent{Locale: "en", Title: "Title", Form: tc.form})
+		if err != nil {
+			t.Fatal(err)
+		}
+		page := string(body)
+		if !strings.Contains(page, `method="post" action="`+tc.form.Action+`"`) || !strings.Contains(page, `name="token" value="abc"`) {
+			t.Errorf("%s: form does not post the token to %s:\n%s", tc.name, tc.form.Action, page)
+		}
+		if got := strings.Contains(page, `type="password"`); got != tc.wantPasswords {
+			t.Errorf("%s: password fields shown = %v, want %v", tc.name, got, tc.wantPasswords)
+		}
+	}
+}
@@ -1,64 +1,233 @@
 package utils
 
 import (
-	"fmt"
-	"log"
-	"net/smtp"
+	"encoding/json"
+	"strconv"
+	"time"
 
 	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/models"
+	"github.com/wanloq/taskinator/internal/repositories"
 )
 
-// Email configuration
-const (
-	SMTPServer  = "smtp.gmail.com"
-	SMTPPort    = "587"
-	linkTimeout = 5
-)
+// Emailed link lifetime, in minutes
+const linkTimeout = 5
 
-// LoadEmailConfig returns email configurations (SMTPUsername and SMTPPassword) or an error .
-func LoadEmailConfig() (string, string, error) {
-	SMTPUsername, err := config.ReadSecretFile("/run/secrets/smtp_username")
-	if err != nil {
-		return "", "", fmt.Errorf("failed to load SMTP username: %w", err)
+// OneTimeTokenTTL is how long emailed password reset and verification links stay valid
+const OneTimeTokenTTL = linkTimeout * time.Minute
+
+// SendPasswordResetEmail sends an email with a password reset link
+func SendPasswordResetEmail(user *models.User, resetToken string) error {
+	email := newEmailContent(user, "password_reset.title")
+	email.Paragraphs = []string{email.t("password_reset.intro")}
+	email.Action = &emailAction{
+		URL:   tokenLink(PageURL, ResetPasswordPath, resetToken),
+		Label: email.t("password_reset.action"),
 	}
-	SMTPPassword, err := config.ReadSecretFile("/run/secrets/smtp_password")
-	if err != nil {
-		return "", "", fmt.Errorf("failed to load SMTP password: %w", err)
+	email.expiresAt = time.Now().Add(OneTimeTokenTTL)
+	email.Notes = []string{email.t("common.link_expiry", "minutes", strconv.Itoa(linkTimeout))}
+
+	return sendEmail(user, user.Email, email)
+}
+
+// SendVerificationEmail sends an email verification link
+func SendVerificationEmail(user *models.User, verificationToken string) error {
+	email := newEmailContent(user, "verification.title")
+	email.Paragraphs = []string{email.t("verification.intro")}
+	email.Action = &emailAction{
+		URL:   tokenLink(PageURL, VerifyEmailPath, verificationToken),
+		Label: email.t("verification.action"),
 	}
-	return SMTPUsername, SMTPPassword, nil
+	email.expiresAt = time.Now().Add(OneTimeTokenTTL)
+	email.Notes = []string{email.t("common.link_expiry", "minutes", strconv.Itoa(linkTimeout))}
+
+	return sendEmail(user, user.Email, email)
 }
 
-// SendPasswordResetEmail sends an email with a password reset link
-func SendPasswordResetEmail(toEmail string, resetToken string) error {
-	resetLink := fmt.Sprintf("http://0.0.0.0:8080/user/password-reset/confirm?token=%s", resetToken)
-	subject := "Taskinator - Password Reset Request"
-	note := fmt.Sprintf("Please note that the link expires in %v minutes", linkTimeout)
-	body := fmt.Sprintf("Click the link below to reset your Taskinator password:\n\n%s \n\n%s", resetLink, note)
-
-	log.Println("Email Content!\n", body)
-	return sendEmail(toEmail, subject, body)
+// SendMagicLinkEmail sends a one-time login link
+func SendMagicLinkEmail(user *models.User, loginToken string) error {
+	email := newEmailContent(user, "magic_link.title")
+	email.Paragraphs = []string{email.t("magic_link.intro")}
+	email.Action = &emailAction{
+		URL:   tokenLink(PublicURL, "/api/login/magic/verify", loginToken),
+		Label: email.t("magic_link.action"),
+	}
+	email.expiresAt = time.Now().Add(OneTimeTokenTTL)
+	email.Notes = []string{email.t("magic_link.note", "minutes", strconv.Itoa(linkTimeout)), email.t("common.ignore")}
+
+	return sendEmail(user, user.Email, email)
 }
 
-// SendVerificationEmail sends an email verification link
-func SendVerificationEmail(toEmail string, verificationToken string) error {
-	verificationLink := fmt.Sprintf("http://0.0.0.0:8080/user/email/verify?token=%s", verificationToken)
-	subject := "Taskinator - Verify Your Email"
-	note := fmt.Sprintf("Please note that the link expires in %v minutes", linkTimeout)
-	body := fmt.Sprintf("Click the link below to verify your email on Taskinator:\n\n%s\n\n%s", verificationLink, note)
-
-	log.Println("Email Content!\n", body)
-	return sendEmail(toEmail, subject, body)
+// SendEmailChangeConfirmationEmail sends the new address a link that confirms it
+func SendEmailChangeConfirmationEmail(user *models.User, newEmail, confirmToken string) error {
+	email := newEmailContent(user, "email_change_confirm.title")
+	email.Paragraphs = []string{email.t("email_change_confirm.intro")}
+	email.Action = &emailAction{
+		URL:   tokenLink(PageURL, ConfirmEmailChangePath, confirmToken),
+		Label: email.t("email_change_confirm.action"),
+	}
+	email.expiresAt = time.Now().Add(OneTimeTokenTTL)
+	email.Notes = []string{email.t("common.link_expiry", "minutes", strconv.Itoa(linkTimeout)), email.t("common.ignore")}
+
+	return sendEmail(user, newEmail, email)
 }
 
-// Helper function to send email
-func sendEmail(toEmail, subject, body string) error {
-	SMTPUsername, SMTPPassword, err := LoadEmailConfig()
+// SendEmailChangeNoticeEmail tells the current address about a requested change and how to undo it
+func SendEmailChangeNoticeEmail(user *models.User, newEmail, revertToken string, revertWindow time.Duration) error {
+	email := newEmailContent(user, "email_change_notice.title")
+	email.Paragraphs = []string{
+		email.t("email_change_notice.intro", "email", newEmail),
+		email.t("email_change_notice.warning"),
+	}
+	email.Action = &emailAction{
+		URL:   tokenLink(PageURL, RevertEmailChangePath, revertToken),
+		Label: email.t("email_change_notice.action"),
+	}
+	email.expiresAt = time.Now().Add(revertWindow)
+	email.Notes = []string{email.t("email_change_notice.note", "days", strconv.Itoa(int(revertWindow.Hours()/24)))}
+
+	return notifyUser(user, models.NotificationSecurity, email, "")
+}
+
+// SendInvitationEmail invites an imported user to choose a password
+func SendInvitationEmail(user *models.User, invitationToken string, validFor time.Duration) error {
+	email := newEmailContent(user, "invitation.title")
+	email.Paragraphs = []string{
+		email.t("invitation.intro", "username", user.Username),
+		email.t("invitation.instructions"),
+	}
+	email.Action = &emailAction{
+		URL:   tokenLink(PageURL, AcceptInvitationPath, invitationToken),
+		Label: email.t("invitation.action"),
+	}
+	email.expiresAt = time.Now().Add(validFor)
+	email.Notes = []string{email.t("invitation.note", "days", strconv.Itoa(int(validFor.Hours()/24)))}
+
+	return sendEmail(user, user.Email, email)
+}
+
+// SendAlreadyRegisteredEmail tells the owner of an address that someone tried to register with it
+func SendAlreadyRegisteredEmail(user *models.User) error {
+	email := newEmailContent(user, "already_registered.title")
+	email.Paragraphs = []string{
+		email.t("already_registered.intro"),
+		email.t("already_registered.if_you"),
+		email.t("already_registered.if_not"),
+	}
+
+	return notifyUser(user, models.NotificationSecurity, email, "")
+}
+
+// SendUsernameTakenEmail tells the owner of an address that their registration used a taken username.
+// There is no account yet, so the email is addressed to the registration request.
+func SendUsernameTakenEmail(email, username string) error {
+	requester := &models.User{Username: username, Email: email}
+	content := newEmailContent(requester, "username_taken.title")
+	content.Paragraphs = []string{
+		content.t("username_taken.intro", "username", username),
+		content.t("username_taken.retry"),
+	}
+
+	return sendEmail(requester, email, content)
+}
+
+// SendAccountLockedEmail notifies a user that their account was locked after repeated failed logins
+func SendAccountLockedEmail(user *models.User, lockedUntil time.Time) error {
+	email := newEmailContent(user, "account_locked.title")
+	email.Paragraphs = []string{
+		email.t("account_locked.intro", "time", formatUserTime(user, lockedUntil)),
+		email.t("account_locked.if_you"),
+		email.t("account_locked.if_not"),
+	}
+
+	return notifyUser(user, models.NotificationSecurity, email, "")
+}
+
+// SendAccountDeletionScheduledEmail confirms a self-service deletion request and how to cancel it
+func SendAccountDeletionScheduledEmail(user *models.User, deleteAt time.Time) error {
+	email := newEmailContent(user, "deletion_scheduled.title")
+	email.Paragraphs = []string{
+		email.t("deletion_scheduled.intro", "time", formatUserTime(user, deleteAt)),
+		email.t("deletion_scheduled.cancel"),
+		email.t("deletion_scheduled.if_not"),
+	}
+
+	return notifyUser(user, models.NotificationSecurity, email, "")
+}
+
+// SendAccountSuspendedEmail tells a user their account was suspended or locked, why and for how long
+func SendAccountSuspendedEmail(user *models.User, status, reason string, until *time.Time) error {
+	state := "suspended"
+	if status == models.UserStatusLocked {
+		state = "locked"
+	}
+	email := newEmailContent(user, state+".title")
+	duration := email.t("status.indefinite")
+	if until != nil {
+		duration = email.t("status.until", "time", formatUserTime(user, *until))
+	}
+	email.Paragraphs = []string{email.t(state+".intro", "duration", duration)}
+	if reason != "" {
+		email.Paragraphs = append(email.Paragraphs, email.t("status.reason", "reason", reason))
+	}
+	email.Paragraphs = append(email.Paragraphs, email.t("status.contact"))
+
+	return notifyUser(user, models.NotificationSecurity, email, "")
+}
+
+// SendAccountReinstatedEmail tells a user their account is active again
+func SendAccountReinstatedEmail(user *models.User) error {
+	email := newEmailContent(user, "reinstated.title")
+	email.Paragraphs = []string{email.t("reinstated.intro")}
+
+	return notifyUser(user, models.NotificationSecurity, email, "")
+}
+
+// Helper function to send email: the content is rendered for the user and queued in the outbox
+// for the delivery workers, or handed straight to the mailer when there is no database or no mail backend
+func sendEmail(user *models.User, toEmail string, email *emailContent) error {
+	text, html, err := email.render()
+	if err != nil {
+		return err
+	}
+	msg := EmailMessage{
+		From:     config.Mail.From,
+		To:       toEmail,
+		ToName:   recipientName(user),
+		Subject:  email.Subject,
+		Locale:   email.Locale,
+		TextBody: text,
+		HTMLBody: html,
+	}
+	if !email.expiresAt.IsZero() {
+		msg.ExpiresAt = &email.expiresAt
+	}
+	if msg.MessageID, err = newMessageID(msg.From); err != nil {
+		return err
+	}
+	if config.DB == nil || !MailEnabled() {
+		return CurrentMailer().Send(msg)
+	}
+	return QueueEmail(msg)
+}
+
+// QueueEmail stores msg in the outbox. Malformed messages are rejected here rather than
+// being dead-lettered later.
+func QueueEmail(msg EmailMessage) error {
+	if _, err := msg.Bytes(); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(msg)
 	if err != nil {
-		log.Fatalf("Error loading SMTP credentials: %v", err)
 		return err
 	}
-	auth := smtp.PlainAuth("", SMTPUsername, SMTPPassword, SMTPServer)
-	msg := []byte("Subject: " + subject + "\r\n\r\n" + body)
-	log.Println("Email sent!")
-	return smtp.SendMail(SMTPServer+":"+SMTPPort, auth, SMTPUsername, []string{toEmail}, msg)
+	return repositories.EnqueueEmail(&models.EmailOutbox{
+		Recipient:     msg.To,
+		Subject:       msg.Subject,
+		Payload:       string(payload),
+		Status:        models.OutboxPending,
+		MaxAttempts:   config.Mail.MaxAttempts,
+		NextAttemptAt: time.Now(),
+		ExpiresAt:     msg.ExpiresAt,
+	})
 }
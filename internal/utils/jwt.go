@@ -2,15 +2,17 @@ package utils
 
 import (
 	"errors"
-	"log"
 	"time"
 
-	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/wanloq/taskinator/internal/config"
 	"github.com/wanloq/taskinator/internal/dto"
 )
 
+// sessionAudience marks tokens that authenticate API requests, so other tokens signed with the
+// same key, such as OIDC login state, are never accepted as a session
+const sessionAudience = "session"
+
 // GenerateJWT creates a JWT token
 func GenerateJWT(userID uint, email, role string) (string, error) {
 	claims := dto.Claims{
@@ -18,6 +20,7 @@ func GenerateJWT(userID uint, email, role string) (string, error) {
 		Email:  email,
 		Role:   role,
 		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{sessionAudience},
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(12 * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
@@ -27,11 +30,32 @@ func GenerateJWT(userID uint, email, role string) (string, error) {
 	return token.SignedString(config.JWTSecretKey)
 }
 
+// GenerateImpersonationJWT creates a short-lived token that acts as the target user on behalf of actorID
+func GenerateImpersonationJWT(userID uint, email, role string, actorID uint, ttl time.Duration, readOnly bool) (string, time.Time, error) {
+	expiresAt := time.Now().Add(ttl)
+	claims := dto.Claims{
+		UserID:   userID,
+		Email:    email,
+		Role:     role,
+		ActorID:  actorID,
+		ReadOnly: readOnly,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{sessionAudience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(config.JWTSecretKey)
+	return signed, expiresAt, err
+}
+
 // VerifyJWT verifies and extracts claims from a token
 func VerifyJWT(tokenString string) (*dto.Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &dto.Claims{}, func(t *jwt.Token) (interface{}, error) {
 		return config.JWTSecretKey, nil
-	})
+	}, jwt.WithAudience(sessionAudience), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
 	if err != nil {
 		return nil, errors.New("invalid token")
@@ -44,120 +68,3 @@ func VerifyJWT(tokenString string) (*dto.Claims, error) {
 
 	return claims, nil
 }
-
-// ExtractUserIDFromToken extracts the user_id from the JWT token
-func ExtractUserIDFromToken(c *fiber.Ctx) (uint, error) {
-	// Get the token from the request header
-	authHeader := c.Get("Authorization")
-	if authHeader == "" {
-		return 0, errors.New("missing token")
-	}
-
-	// Extract the token (remove "Bearer " prefix)
-	tokenString := authHeader[7:]
-
-	// Parse token
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return config.JWTSecretKey, nil
-	})
-	if err != nil {
-		return 0, err
-	}
-
-	// Extract claims
-	if claims, ok := token.Claims.(*jwt.MapClaims); ok && token.Valid {
-		userIDFloat, ok := (*claims)["user_id"].(float64)
-		if !ok {
-			return 0, errors.New("invalid token claims")
-		}
-		return uint(userIDFloat), nil
-	}
-
-	return 0, errors.New("invalid token")
-}
-
-func ExtractUserFromToken(c *fiber.Ctx) (uint, string, error) {
-	// Get the token from the request header
-	authHeader := c.Get("Authorization")
-	if authHeader == "" {
-		return 0, "", errors.New("missing token")
-	}
-
-	// Extract the token (remove "Bearer " prefix)
-	tokenString := authHeader[7:]
-
-	// Parse token
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.MapClaims{}, func(t *jwt.Token) (interface{}, error) {
-		return config.JWTSecretKey, nil
-	})
-	if err != nil {
-		return 0, "", err
-	}
-
-	// Extract claims
-	if claims, ok := token.Claims.(*jwt.MapClaims); ok && token.Valid {
-		userID := uint((*claims)["user_id"].(float64))
-		role := (*claims)["role"].(string)
-		return userID, role, nil
-	}
-
-	return 0, "", errors.New("invalid token")
-}
-
-func GenerateEmailVerificationToken(email string) (string, error) {
-	claims := jwt.MapClaims{
-		"email": email,
-		"exp":   jwt.NewNumericDate(time.Now().Add(5 * time.Minute)),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(config.JWTSecretKey)
-}
-
-func VerifyEmailToken(tokenString string) (string, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.MapClaims{}, func(t *jwt.Token) (interface{}, error) {
-		return config.JWTSecretKey, nil
-	})
-	if err != nil {
-		return "", err
-	}
-
-	if claims, ok := token.Claims.(*jwt.MapClaims); ok && token.Valid {
-		return (*claims)["email"].(string), nil
-	}
-
-	return "", errors.New("invalid token")
-}
-
-// GeneratePasswordResetToken creates a JWT token for password reset
-func GeneratePasswordResetToken(email string) (string, error) {
-	claims := jwt.MapClaims{
-		"email": email,
-		"exp":   time.Now().Add(5 * time.Minute).Unix(),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(config.JWTSecretKey)
-}
-
-// VerifyPasswordResetToken verifies the JWT password reset token
-func VerifyPasswordResetToken(tokenString string) (string, error) {
-	token, err := jwt.ParseWithClaims(tokenString, jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return config.JWTSecretKey, nil
-	})
-	if err != nil {
-		return "", err
-	}
-
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
-		return "", errors.New("invalid or expired token")
-	}
-
-	email, exists := claims["email"].(string)
-	if !exists {
-		return "", errors.New("invalid token claims")
-	}
-	log.Println("Password reset token Verified")
-	return email, nil
-}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/dto"
+)
+
+func TestVerifyJWTAcceptsOnlySessionTokens(t *testing.T) {
+	saved := config.JWTSecretKey
+	config.JWTSecretKey = []byte("session-secret")
+	t.Cleanup(func() { config.JWTSecretKey = saved })
+
+	session, err := GenerateJWT(7, "jane@example.com", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, err := VerifyJWT(session); err != nil || claims.UserID != 7 {
+		t.Fatalf("session token rejected: %+v %v", claims, err)
+	}
+	impersonation, _, err := GenerateImpersonationJWT(7, "jane@example.com", "user", 1, time.Minute, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, err := VerifyJWT(impersonation); err != nil || claims.ActorID != 1 {
+		t.Fatalf("impersonation token rejected: %+v %v", claims, err)
+	}
+
+	sign := func(method jwt.SigningMethod, audience ...string) string {
+		claims := dto.Claims{UserID: 7, RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  audience,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}}
+		signed, err := jwt.NewWithClaims(method, claims).SignedString(config.JWTSecretKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	stateToken, err := GenerateOIDCStateToken(OIDCState{Provider: "mock", State: "state-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, token := range map[string]string{
+		"OIDC login state":  stateToken,
+		"no audience":       sign(jwt.SigningMethodHS256),
+		"another audience":  sign(jwt.SigningMethodHS256, "oidc-state"),
+		"another algorithm": sign(jwt.SigningMethodHS512, sessionAudience),
+	} {
+		if _, err := VerifyJWT(token); err == nil {
+			t.Errorf("%s: token was accepted as a session", name)
+		}
+	}
+}
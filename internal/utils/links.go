@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"net/url"
+
+	"github.com/wanloq/taskinator/internal/config"
+)
+
+// Paths of the pages emailed links open. They are served by this server, or by the frontend when
+// FRONTEND_URL is set.
+const (
+	ResetPasswordPath      = "/reset-password"
+	VerifyEmailPath        = "/verify-email"
+	AcceptInvitationPath   = "/accept-invitation"
+	ConfirmEmailChangePath = "/confirm-email-change"
+	RevertEmailChangePath  = "/revert-email-change"
+)
+
+// PublicURL returns the absolute URL of a path on this server with the given query
+func PublicURL(path string, query url.Values) string {
+	return buildURL(config.URLs.PublicBaseURL, path, query)
+}
+
+// PageURL returns the absolute URL of a user-facing page: on the frontend when one is configured,
+// otherwise on this server
+func PageURL(path string, query url.Values) string {
+	if config.URLs.FrontendURL != "" {
+		return buildURL(config.URLs.FrontendURL, path, query)
+	}
+	return PublicURL(path, query)
+}
+
+// tokenLink builds a link carrying a single-use token
+func tokenLink(build func(string, url.Values) string, path, token string) string {
+	return build(path, url.Values{"token": {token}})
+}
+
+// buildURL joins a base URL, a path and an optional query
+func buildURL(base, path string, query url.Values) string {
+	link := base + path
+	if len(query) > 0 {
+		link += "?" + query.Encode()
+	}
+	return link
+}
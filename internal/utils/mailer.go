@@ -0,0 +1,376 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wanloq/taskinator/internal/config"
+)
+
+// ErrMailDisabled is returned when no mail backend is configured
+var ErrMailDisabled = errors.New("outgoing email is not configured")
+
+// ErrInvalidEmail is returned for messages that can never be delivered as written
+var ErrInvalidEmail = errors.New("invalid email")
+
+// ErrMessageRejected is returned when the SMTP server refuses the recipient or content of a message.
+// Failures before that point (connecting, authenticating, MAIL FROM) concern the server setup, not the message.
+var ErrMessageRejected = errors.New("message rejected")
+
+// smtpTimeout bounds connecting to and talking with the SMTP server
+const smtpTimeout = 30 * time.Second
+
+// EmailMessage is one outgoing email
+type EmailMessage struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// ToName is the recipient's display name in the To header
+	ToName  string `json:"to_name,omitempty"`
+	Subject string `json:"subject"`
+	// Locale is sent as Content-Language
+	Locale string `json:"locale,omitempty"`
+	// MessageID is generated when the message is created so retried deliveries keep the same ID
+	MessageID string `json:"message_id,omitempty"`
+	TextBody  string `json:"text_body"`
+	// HTMLBody, when set, is sent as the multipart/alternative companion of TextBody
+	HTMLBody string `json:"html_body,omitempty"`
+	// ExpiresAt, when set, is when the link in the message stops working, so delivery is pointless after it
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Mailer delivers outgoing email
+type Mailer interface {
+	Send(msg EmailMessage) error
+}
+
+var (
+	mailerMu     sync.RWMutex
+	activeMailer Mailer = disabledMailer{}
+)
+
+// SetMailer replaces the mailer used by the Send*Email helpers
+func SetMailer(m Mailer) {
+	mailerMu.Lock()
+	defer mailerMu.Unlock()
+	activeMailer = m
+}
+
+// CurrentMailer returns the mailer used by the Send*Email helpers
+func CurrentMailer() Mailer {
+	mailerMu.RLock()
+	defer mailerMu.RUnlock()
+	return activeMailer
+}
+
+// MailEnabled reports whether a mail backend is configured
+func MailEnabled() bool {
+	_, disabled := CurrentMailer().(disabledMailer)
+	return !disabled
+}
+
+// IsPermanentMailError reports whether retrying a failed send cannot succeed: the message is
+// malformed or the SMTP server rejected its recipient or content with a 5xx reply
+func IsPermanentMailError(err error) bool {
+	return errors.Is(err, ErrInvalidEmail) || errors.Is(err, ErrMessageRejected)
+}
+
+// rejected marks a 5xx reply to RCPT TO or DATA as a rejection of the message itself
+func rejected(err error) error {
+	var reply *textproto.Error
+	if errors.As(err, &reply) && reply.Code >= 500 {
+		return fmt.Errorf("%w: %w", ErrMessageRejected, err)
+	}
+	return err
+}
+
+// InitMailer installs the mailer selected by config.Mail. A backend that cannot be set up
+// leaves email disabled with a warning instead of stopping the server.
+func InitMailer() {
+	m, err := NewMailer(config.Mail)
+	if err != nil {
+		log.Println("Outgoing email disabled:", err)
+		m = disabledMailer{}
+	}
+	if _, disabled := m.(disabledMailer); disabled {
+		log.Println("No mail backend configured; emails will not be sent")
+	} else {
+		log.Println("Mail backend:", config.Mail.Backend)
+	}
+	SetMailer(m)
+}
+
+// NewMailer builds the mailer for the given settings
+func NewMailer(settings config.MailSettings) (Mailer, error) {
+	switch settings.Backend {
+	case config.MailBackendSMTP:
+		if settings.SMTPHost == "" {
+			return nil, errors.New("SMTP_HOST is not set")
+		}
+		return &SMTPMailer{
+			Host:     settings.SMTPHost,
+			Port:     settings.SMTPPort,
+			Username: settings.SMTPUsername,
+			Password: settings.SMTPPassword,
+			Security: settings.SMTPSecurity,
+		}, nil
+	case config.MailBackendFile:
+		if err := os.MkdirAll(settings.FileDir, 0o700); err != nil {
+			return nil, fmt.Errorf("could not create mail directory: %w", err)
+		}
+		return &FileMailer{Dir: settings.FileDir}, nil
+	case config.MailBackendMemory:
+		return &MemoryMailer{}, nil
+	default:
+		return disabledMailer{}, nil
+	}
+}
+
+// SMTPMailer delivers email through an SMTP server
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	// Security is config.SMTPSecurityStartTLS, config.SMTPSecurityTLS or config.SMTPSecurityNone
+	Security string
+}
+
+// Send delivers msg over a fresh SMTP connection
+func (m *SMTPMailer) Send(msg EmailMessage) error {
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return fmt.Errorf("%w: sender: %v", ErrInvalidEmail, err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("%w: recipient: %v", ErrInvalidEmail, err)
+	}
+
+	address := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	tlsConfig := &tls.Config{ServerName: m.Host, MinVersion: tls.VersionTLS12}
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+
+	var conn net.Conn
+	if m.Security == config.SMTPSecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	client, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if m.Security == config.SMTPSecurityStartTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if m.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return rejected(err)
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return rejected(err)
+	}
+	if _, err := writer.Write(data); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return rejected(err)
+	}
+	return client.Quit()
+}
+
+// FileMailer writes each email as an .eml file, for development
+type FileMailer struct {
+	Dir string
+}
+
+// Send writes msg to a new file in Dir
+func (m *FileMailer) Send(msg EmailMessage) error {
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	suffix, err := GenerateRandomToken(4)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), suffix)
+	path := filepath.Join(m.Dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return err
+	}
+	log.Println("Email to", msg.To, "written to", path)
+	return nil
+}
+
+// MemoryMailer keeps sent email in memory, for tests
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []EmailMessage
+}
+
+// Send records msg
+func (m *MemoryMailer) Send(msg EmailMessage) error {
+	if _, err := msg.Bytes(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of every message sent so far
+func (m *MemoryMailer) Messages() []EmailMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]EmailMessage(nil), m.messages...)
+}
+
+// Reset forgets every message sent so far
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = nil
+}
+
+// disabledMailer drops email when no backend is configured
+type disabledMailer struct{}
+
+func (disabledMailer) Send(msg EmailMessage) error {
+	log.Println("Email to", msg.To, "not sent:", ErrMailDisabled)
+	return ErrMailDisabled
+}
+
+// Bytes renders the message in RFC 5322 format, rejecting header injection. A message with an
+// HTML body becomes multipart/alternative with the plain text first, as the fallback.
+func (msg EmailMessage) Bytes() ([]byte, error) {
+	for _, header := range []string{msg.From, msg.To, msg.ToName, msg.Subject, msg.Locale, msg.MessageID} {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, fmt.Errorf("%w: headers must not contain line breaks", ErrInvalidEmail)
+		}
+	}
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return nil, fmt.Errorf("%w: sender: %v", ErrInvalidEmail, err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, fmt.Errorf("%w: recipient: %v", ErrInvalidEmail, err)
+	}
+	if msg.ToName != "" {
+		to.Name = msg.ToName
+	}
+	messageID := msg.MessageID
+	if messageID == "" {
+		if messageID, err = newMessageID(msg.From); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: %s\r\n", messageID)
+	if msg.Locale != "" {
+		fmt.Fprintf(&buf, "Content-Language: %s\r\n", msg.Locale)
+	}
+	buf.WriteString("Auto-Submitted: auto-generated\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if msg.HTMLBody == "" {
+		buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQuotedPrintable(&buf, msg.TextBody); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", parts.Boundary())
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=UTF-8", msg.TextBody},
+		{"text/html; charset=UTF-8", msg.HTMLBody},
+	} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(writer, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeQuotedPrintable writes body to w with CRLF line endings in quoted-printable encoding
+func writeQuotedPrintable(w io.Writer, body string) error {
+	encoder := quotedprintable.NewWriter(w)
+	if _, err := encoder.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// newMessageID generates a unique Message-ID in the sender's domain
+func newMessageID(from string) (string, error) {
+	domain := "localhost"
+	if address, err := mail.ParseAddress(from); err == nil {
+		if i := strings.LastIndex(address.Address, "@"); i >= 0 {
+			domain = address.Address[i+1:]
+		}
+	}
+	id, err := GenerateRandomToken(18)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), id, domain), nil
+}
@@ -0,0 +1,210 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wanloq/taskinator/internal/models"
+)
+
+func testMessage() EmailMessage {
+	return EmailMessage{
+		From:      "Taskinator <noreply@example.com>",
+		To:        "jane@example.com",
+		ToName:    "Jane",
+		Subject:   "Reset your password",
+		Locale:    "en",
+		MessageID: "<1.abc@example.com>",
+		TextBody:  "Hello Jane,\nfollow the link.",
+	}
+}
+
+// parseMessage renders msg and reads it back the way a receiving mail client would
+func parseMessage(t *testing.T, msg EmailMessage) *mail.Message {
+	t.Helper()
+	data, err := msg.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("rendered message does not parse: %v\n%s", err, data)
+	}
+	return parsed
+}
+
+func readQuotedPrintable(t *testing.T, r io.Reader) string {
+	t.Helper()
+	body, err := io.ReadAll(quotedprintable.NewReader(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestEmailMessageBytesRejectsHeaderInjection(t *testing.T) {
+	injections := map[string]func(*EmailMessage){
+		"from":       func(m *EmailMessage) { m.From = "noreply@example.com\r\nBcc: victim@example.com" },
+		"to":         func(m *EmailMessage) { m.To = "jane@example.com\nBcc: victim@example.com" },
+		"to name":    func(m *EmailMessage) { m.ToName = "Jane\r\nBcc: victim@example.com" },
+		"subject":    func(m *EmailMessage) { m.Subject = "Hello\r\nBcc: victim@example.com" },
+		"locale":     func(m *EmailMessage) { m.Locale = "en\nBcc: victim@example.com" },
+		"message id": func(m *EmailMessage) { m.MessageID = "<1@example.com>\rBcc: victim@example.com" },
+	}
+	for name, inject := range injections {
+		t.Run(name, func(t *testing.T) {
+			msg := testMessage()
+			inject(&msg)
+			_, err := msg.Bytes()
+			if !errors.Is(err, ErrInvalidEmail) {
+				t.Fatalf("got %v, want ErrInvalidEmail", err)
+			}
+			if !IsPermanentMailError(err) {
+				t.Fatal("header injection was not treated as permanent")
+			}
+		})
+	}
+}
+
+func TestEmailMessageBytesRejectsInvalidAddresses(t *testing.T) {
+	msg := testMessage()
+	msg.To = "not an address"
+	if _, err := msg.Bytes(); !errors.Is(err, ErrInvalidEmail) {
+		t.Fatalf("got %v, want ErrInvalidEmail", err)
+	}
+}
+
+func TestEmailMessageBytesPlainText(t *testing.T) {
+	parsed := parseMessage(t, testMessage())
+
+	if got := parsed.Header.Get("Content-Type"); got != "text/plain; charset=UTF-8" {
+		t.Fatalf("Content-Type %q", got)
+	}
+	if got := parsed.Header.Get("Message-ID"); got != "<1.abc@example.com>" {
+		t.Fatalf("Message-ID %q, want the stored ID", got)
+	}
+	if got := parsed.Header.Get("Content-Language"); got != "en" {
+		t.Fatalf("Content-Language %q", got)
+	}
+	to, err := parsed.Header.AddressList("To")
+	if err != nil || len(to) != 1 || to[0].Name != "Jane" || to[0].Address != "jane@example.com" {
+		t.Fatalf("To %v, %v", to, err)
+	}
+	if body := readQuotedPrintable(t, parsed.Body); body != "Hello Jane,\r\nfollow the link." {
+		t.Fatalf("body %q", body)
+	}
+}
+
+func TestEmailMessageBytesMultipartAlternative(t *testing.T) {
+	msg := testMessage()
+	msg.HTMLBody = "<p>Hello Jane, <a href=\"https://example.com/reset?token=abc\">follow the link</a>.</p>"
+	parsed := parseMessage(t, msg)
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" || params["boundary"] == "" {
+		t.Fatalf("Content-Type %q, %v", parsed.Header.Get("Content-Type"), err)
+	}
+
+	// The plain text comes first so clients that prefer HTML pick the last part
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	for _, want := range []struct{ contentType, body string }{
+		{"text/plain; charset=UTF-8", "Hello Jane,\r\nfollow the link."},
+		{"text/html; charset=UTF-8", msg.HTMLBody},
+	} {
+		part, err := reader.NextRawPart()
+		if err != nil {
+			t.Fatalf("reading %s part: %v", want.contentType, err)
+		}
+		if got := part.Header.Get("Content-Type"); got != want.contentType {
+			t.Fatalf("part Content-Type %q, want %q", got, want.contentType)
+		}
+		if got := part.Header.Get("Content-Transfer-Encoding"); got != "quoted-printable" {
+			t.Fatalf("part encoding %q", got)
+		}
+		if body := readQuotedPrintable(t, part); body != want.body {
+			t.Fatalf("part body %q, want %q", body, want.body)
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Fatalf("unexpected extra part: %v", err)
+	}
+}
+
+func TestEmailMessageBytesEncodesSubject(t *testing.T) {
+	msg := testMessage()
+	msg.Subject = "Réinitialisez votre mot de passe – Taskinator"
+	parsed := parseMessage(t, msg)
+
+	raw := parsed.Header.Get("Subject")
+	if !strings.HasPrefix(raw, "=?utf-8?q?") {
+		t.Fatalf("subject %q is not Q-encoded", raw)
+	}
+	decoded, err := new(mime.WordDecoder).DecodeHeader(raw)
+	if err != nil || decoded != msg.Subject {
+		t.Fatalf("subject decodes to %q, %v", decoded, err)
+	}
+
+	// ASCII subjects are left readable
+	if raw := parseMessage(t, testMessage()).Header.Get("Subject"); raw != "Reset your password" {
+		t.Fatalf("ASCII subject %q", raw)
+	}
+}
+
+func TestIsPermanentMailError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"invalid message", fmt.Errorf("%w: recipient: missing @", ErrInvalidEmail), true},
+		{"wrapped invalid message", errors.Join(errors.New("decode"), ErrInvalidEmail), true},
+		{"mailbox unavailable", rejected(&textproto.Error{Code: 550, Msg: "mailbox unavailable"}), true},
+		{"wrapped rejection", fmt.Errorf("smtp: %w", rejected(&textproto.Error{Code: 554, Msg: "rejected"})), true},
+		{"greylisted recipient", rejected(&textproto.Error{Code: 451, Msg: "try again later"}), false},
+		{"authentication failed", &textproto.Error{Code: 535, Msg: "authentication credentials invalid"}, false},
+		{"authentication required", &textproto.Error{Code: 530, Msg: "authentication required"}, false},
+		{"sender refused", &textproto.Error{Code: 553, Msg: "sender address not allowed"}, false},
+		{"connection refused", errors.New("dial tcp: connection refused"), false},
+		{"mail disabled", ErrMailDisabled, false},
+	}
+	for _, tc := range cases {
+		if got := IsPermanentMailError(tc.err); got != tc.want {
+			t.Errorf("%s: IsPermanentMailError = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestTokenEmailsCarryLinkExpiry(t *testing.T) {
+	mailer := useMemoryMailer(t)
+	user := &models.User{Username: "jane", Email: "jane@example.com"}
+
+	before := time.Now()
+	if err := SendPasswordResetEmail(user, "reset-token"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SendInvitationEmail(user, "invitation-token", 7*24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	after := time.Now()
+
+	messages := mailer.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("sent %d messages, want 2", len(messages))
+	}
+	for i, ttl := range []time.Duration{OneTimeTokenTTL, 7 * 24 * time.Hour} {
+		expiresAt := messages[i].ExpiresAt
+		if expiresAt == nil || expiresAt.Before(before.Add(ttl)) || expiresAt.After(after.Add(ttl)) {
+			t.Fatalf("message %d expires at %v, want %v after sending", i, expiresAt, ttl)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/models"
+	"github.com/wanloq/taskinator/internal/repositories"
+)
+
+// SendResourceSharedNotification tells a user that a task or project was shared with them
+func SendResourceSharedNotification(user *models.User, sharedBy *models.User, resourceType string, resourceID uint, access string) error {
+	email := newEmailContent(user, "shared."+resourceType+".title")
+	email.Paragraphs = []string{email.t("shared."+resourceType+".intro",
+		"name", recipientName(sharedBy),
+		"id", strconv.FormatUint(uint64(resourceID), 10),
+		"access", email.t("access."+access),
+	)}
+
+	// Resources have no page on this server, so only a frontend can be linked from the email
+	link := fmt.Sprintf("/%ss/%d", resourceType, resourceID)
+	if config.URLs.FrontendURL != "" {
+		email.Action = &emailAction{URL: PageURL(link, nil), Label: email.t("shared.action")}
+	}
+
+	return notifyUser(user, models.NotificationAssignment, email, link)
+}
+
+// notifyUser delivers a notification on the channels the user chose for its event type: an entry in
+// their notification center and an email with the same content. link is stored with the in-app entry;
+// it must never carry a token.
+func notifyUser(user *models.User, eventType string, email *emailContent, link string) error {
+	preference := models.DefaultNotificationPreference(user.ID, eventType)
+	inAppCreated := false
+	if config.DB != nil {
+		saved, err := repositories.GetNotificationPreference(user.ID, eventType)
+		if err != nil {
+			log.Println("Could not load notification preference for user", user.ID, err)
+		} else {
+			preference = saved
+		}
+
+		if preference.InApp {
+			notification := models.Notification{
+				UserID: user.ID,
+				Type:   eventType,
+				Title:  email.Title,
+				Body:   strings.Join(email.Paragraphs, "\n\n"),
+				Link:   link,
+			}
+			if err := repositories.CreateNotification(&notification); err != nil {
+				log.Println("Could not create notification for user", user.ID, err)
+			} else {
+				inAppCreated = true
+			}
+		}
+	}
+
+	if !emailEventNow(user, eventType, preference, inAppCreated) {
+		return nil
+	}
+	return sendEmail(user, user.Email, email)
+}
+
+// emailEventNow decides whether an event is emailed as it happens. Security alerts always are.
+// Other events are left to the user's digest only when an in-app entry was stored, since the
+// digest is built from those entries; otherwise the email preference decides.
+func emailEventNow(user *models.User, eventType string, preference models.NotificationPreference, inAppCreated bool) bool {
+	if models.EmailRequired(eventType) {
+		return true
+	}
+	if !preference.Email {
+		return false
+	}
+	return !(inAppCreated && user.DigestEnabled())
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/models"
+)
+
+// useMemoryMailer sends email to a MemoryMailer from a valid sender for the rest of the test
+func useMemoryMailer(t *testing.T) *MemoryMailer {
+	t.Helper()
+	from := config.Mail.From
+	config.Mail.From = "Taskinator <noreply@example.com>"
+	mailer := &MemoryMailer{}
+	SetMailer(mailer)
+	t.Cleanup(func() {
+		config.Mail.From = from
+		SetMailer(disabledMailer{})
+	})
+	return mailer
+}
+
+func TestEmailEventNow(t *testing.T) {
+	emailOnly := models.NotificationPreference{InApp: false, Email: true}
+	inAppOnly := models.NotificationPreference{InApp: true, Email: false}
+	both := models.NotificationPreference{InApp: true, Email: true}
+	neither := models.NotificationPreference{}
+
+	cases := []struct {
+		name         string
+		frequency    string
+		eventType    string
+		preference   models.NotificationPreference
+		inAppCreated bool
+		want         bool
+	}{
+		{"no digest, both channels", models.DigestOff, models.NotificationAssignment, both, true, true},
+		{"no digest, in-app only", models.DigestOff, models.NotificationAssignment, inAppOnly, true, false},
+		{"digest carries the in-app entry", models.DigestDaily, models.NotificationAssignment, both, true, false},
+		{"digest, email-only preference", models.DigestDaily, models.NotificationAssignment, emailOnly, false, true},
+		{"digest, in-app entry could not be stored", models.DigestWeekly, models.NotificationAssignment, both, false, true},
+		{"digest, in-app only", models.DigestDaily, models.NotificationAssignment, inAppOnly, true, false},
+		{"security alert with digest", models.DigestDaily, models.NotificationSecurity, both, true, true},
+		{"security alert with email off", models.DigestOff, models.NotificationSecurity, neither, false, true},
+	}
+	for _, tc := range cases {
+		user := &models.User{DigestFrequency: tc.frequency}
+		if got := emailEventNow(user, tc.eventType, tc.preference, tc.inAppCreated); got != tc.want {
+			t.Errorf("%s: emailEventNow = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNotifyUserEmailsWithoutAnInAppEntry(t *testing.T) {
+	mailer := useMemoryMailer(t)
+	sharedBy := &models.User{Username: "john", Email: "john@example.com"}
+
+	// Without a database no in-app entry is stored, so nothing may be left to the digest
+	for _, frequency := range []string{models.DigestOff, models.DigestDaily, models.DigestWeekly} {
+		mailer.Reset()
+		user := &models.User{Username: "jane", Email: "jane@example.com", DigestFrequency: frequency}
+		if err := SendResourceSharedNotification(user, sharedBy, "task", 7, "viewer"); err != nil {
+			t.Fatal(err)
+		}
+		if err := SendAccountReinstatedEmail(user); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(mailer.Messages()); got != 2 {
+			t.Errorf("digest %s: sent %d emails, want 2", frequency, got)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/wanloq/taskinator/internal/config"
+)
+
+const (
+	oidcStateAudience = "oidc-state"
+	oidcStateTimeout  = 10 * time.Minute
+	// oidcDiscoveryTTL bounds how long provider metadata is cached, so endpoint changes are picked up without a restart
+	oidcDiscoveryTTL = time.Hour
+)
+
+var oidcHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// oidcDiscovery is the subset of the provider metadata document used by the login flow
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// cachedDiscovery is a metadata document together with the time it was fetched
+type cachedDiscovery struct {
+	discovery *oidcDiscovery
+	fetchedAt time.Time
+}
+
+// discoveryCache maps an issuer to its *cachedDiscovery
+var discoveryCache sync.Map
+
+// OIDCIdentity is the verified identity asserted by a provider's ID token
+type OIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// OIDCState is carried in a signed cookie between the login redirect and the callback
+type OIDCState struct {
+	Provider     string `json:"provider"`
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	jwt.RegisteredClaims
+}
+
+// GeneratePKCE returns a PKCE code verifier and its S256 code challenge
+func GeneratePKCE() (verifier string, challenge string, err error) {
+	verifier, err = GenerateRandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// GenerateOIDCStateToken signs the login state so the callback can verify it without server storage
+func GenerateOIDCStateToken(state OIDCState) (string, error) {
+	state.RegisteredClaims = jwt.RegisteredClaims{
+		Audience:  jwt.ClaimStrings{oidcStateAudience},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(oidcStateTimeout)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, state)
+	return token.SignedString(config.JWTSecretKey)
+}
+
+// VerifyOIDCStateToken verifies a signed login state cookie
+func VerifyOIDCStateToken(tokenString string) (*OIDCState, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &OIDCState{}, func(t *jwt.Token) (interface{}, error) {
+		return config.JWTSecretKey, nil
+	}, jwt.WithAudience(oidcStateAudience), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, errors.New("invalid login state")
+	}
+
+	state, ok := token.Claims.(*OIDCState)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid login state")
+	}
+	return state, nil
+}
+
+// OIDCAuthURL builds the provider authorization URL for the authorization-code flow with PKCE
+func OIDCAuthURL(provider config.OIDCProvider, state, nonce, codeChallenge string) (string, error) {
+	discovery, err := discoverOIDC(provider.Issuer)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", provider.ClientID)
+	query.Set("redirect_uri", provider.RedirectURL)
+	query.Set("scope", strings.Join(provider.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// ExchangeOIDCCode redeems an authorization code and returns the raw ID token
+func ExchangeOIDCCode(provider config.OIDCProvider, code, codeVerifier string) (string, error) {
+	discovery, err := discoverOIDC(provider.Issuer)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", provider.RedirectURL)
+	form.Set("client_id", provider.ClientID)
+	form.Set("code_verifier", codeVerifier)
+	if provider.ClientSecret != "" {
+		form.Set("client_secret", provider.ClientSecret)
+	}
+
+	resp, err := oidcHTTPClient.PostForm(discovery.TokenEndpoint, form)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.IDToken == "" {
+		return "", fmt.Errorf("token request rejected: %s %s", resp.Status, body.Error)
+	}
+	return body.IDToken, nil
+}
+
+// VerifyOIDCIDToken validates an ID token's signature, issuer, audience, expiry and nonce
+func VerifyOIDCIDToken(provider config.OIDCProvider, rawIDToken, nonce string) (*OIDCIdentity, error) {
+	discovery, err := discoverOIDC(provider.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := fetchJWKS(discovery.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(rawIDToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if key, ok := keys[kid]; ok {
+			return key, nil
+		}
+		// Providers with a single key may omit the kid
+		if kid == "" && len(keys) == 1 {
+			for _, key := range keys {
+				return key, nil
+			}
+		}
+		return nil, errors.New("unknown signing key")
+	},
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(provider.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384"}),
+	)
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce == "" || tokenNonce != nonce {
+		return nil, errors.New("invalid ID token nonce")
+	}
+
+	identity := &OIDCIdentity{}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	// Some providers encode email_verified as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+
+	if identity.Subject == "" {
+		return nil, errors.New("ID token has no subject")
+	}
+	return identity, nil
+}
+
+// discoverOIDC fetches the provider's metadata document, caching it for oidcDiscoveryTTL
+func discoverOIDC(issuer string) (*oidcDiscovery, error) {
+	if cached, ok := discoveryCache.Load(issuer); ok {
+		entry := cached.(*cachedDiscovery)
+		if time.Since(entry.fetchedAt) < oidcDiscoveryTTL {
+			return entry.discovery, nil
+		}
+	}
+
+	resp, err := oidcHTTPClient.Get(issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OIDC discovery failed: %s", resp.Status)
+	}
+
+	var discovery oidcDiscovery
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return nil, fmt.Errorf("invalid OIDC discovery document: %w", err)
+	}
+	if strings.TrimRight(discovery.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("OIDC issuer mismatch: got %q", discovery.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("incomplete OIDC discovery document")
+	}
+
+	discoveryCache.Store(issuer, &cachedDiscovery{discovery: &discovery, fetchedAt: time.Now()})
+	return &discovery, nil
+}
+
+// fetchJWKS downloads the provider's signing keys indexed by key ID.
+// Keys are fetched per login so rotated keys are picked up without a restart.
+func fetchJWKS(jwksURI string) (map[string]interface{}, error) {
+	resp, err := oidcHTTPClient.Get(jwksURI)
+	if err != nil {
+		return nil, fmt.Errorf("JWKS request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS request failed: %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	keys := make(map[string]interface{})
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			default:
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS contains no usable signing keys")
+	}
+	return keys, nil
+}
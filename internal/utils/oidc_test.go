@@ -0,0 +1,292 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/wanloq/taskinator/internal/config"
+)
+
+const (
+	mockClientID    = "taskinator"
+	mockRedirectURL = "http://localhost/api/auth/oidc/mock/callback"
+	mockKeyID       = "test-key"
+)
+
+// mockAuthorization is what the mock provider remembers about an issued authorization code
+type mockAuthorization struct {
+	challenge string
+	nonce     string
+}
+
+// mockOIDCProvider serves discovery, JWKS and token endpoints like a real identity provider
+type mockOIDCProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	// signingKey signs issued ID tokens; it differs from key to simulate a forged token
+	signingKey *rsa.PrivateKey
+
+	mu             sync.Mutex
+	codes          map[string]mockAuthorization
+	discoveryCalls int
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockOIDCProvider{key: key, signingKey: key, codes: map[string]mockAuthorization{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.serveDiscovery)
+	mux.HandleFunc("/jwks", m.serveJWKS)
+	mux.HandleFunc("/token", m.serveToken)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockOIDCProvider) provider() config.OIDCProvider {
+	return config.OIDCProvider{
+		Name:        "mock",
+		Issuer:      m.server.URL,
+		ClientID:    mockClientID,
+		RedirectURL: mockRedirectURL,
+		Scopes:      []string{"openid", "email"},
+	}
+}
+
+// authorize stands in for the user approving the login at the provider and returns the code
+func (m *mockOIDCProvider) authorize(t *testing.T, challenge, nonce string) string {
+	t.Helper()
+	code, err := GenerateRandomToken(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.codes[code] = mockAuthorization{challenge: challenge, nonce: nonce}
+	return code
+}
+
+func (m *mockOIDCProvider) serveDiscovery(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	m.discoveryCalls++
+	m.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 m.server.URL,
+		"authorization_endpoint": m.server.URL + "/authorize",
+		"token_endpoint":         m.server.URL + "/token",
+		"jwks_uri":               m.server.URL + "/jwks",
+	})
+}
+
+func (m *mockOIDCProvider) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": mockKeyID,
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}},
+	})
+}
+
+// serveToken redeems a code once, checking the PKCE verifier against the challenge sent at authorization
+func (m *mockOIDCProvider) serveToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	m.mu.Lock()
+	authorization, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case !ok, r.PostForm.Get("client_id") != mockClientID, r.PostForm.Get("redirect_uri") != mockRedirectURL:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	case base64.RawURLEncoding.EncodeToString(sum[:]) != authorization.challenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            m.server.URL,
+		"aud":            mockClientID,
+		"sub":            "subject-1",
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"name":           "Jane",
+		"nonce":          authorization.nonce,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Minute).Unix(),
+	})
+	token.Header["kid"] = mockKeyID
+	idToken, err := token.SignedString(m.signingKey)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id_token": idToken, "token_type": "Bearer"})
+}
+
+func (m *mockOIDCProvider) discoveryCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.discoveryCalls
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// startLogin runs the redirect half of the flow and returns the code verifier, nonce and code
+func startLogin(t *testing.T, m *mockOIDCProvider) (verifier, nonce, code string) {
+	t.Helper()
+	verifier, challenge, err := GeneratePKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := OIDCAuthURL(m.provider(), "state-1", "nonce-1", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if !strings.HasPrefix(authURL, m.server.URL+"/authorize?") {
+		t.Fatalf("authorization URL %q does not use the discovered endpoint", authURL)
+	}
+	if query.Get("code_challenge") != challenge || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("authorization URL %q does not carry the S256 PKCE challenge", authURL)
+	}
+	if query.Get("state") != "state-1" || query.Get("client_id") != mockClientID {
+		t.Fatalf("authorization URL %q has the wrong state or client", authURL)
+	}
+	return verifier, query.Get("nonce"), m.authorize(t, query.Get("code_challenge"), query.Get("nonce"))
+}
+
+func TestOIDCLoginFlow(t *testing.T) {
+	m := newMockOIDCProvider(t)
+	verifier, nonce, code := startLogin(t, m)
+
+	idToken, err := ExchangeOIDCCode(m.provider(), code, verifier)
+	if err != nil {
+		t.Fatalf("ExchangeOIDCCode: %v", err)
+	}
+	identity, err := VerifyOIDCIDToken(m.provider(), idToken, nonce)
+	if err != nil {
+		t.Fatalf("VerifyOIDCIDToken: %v", err)
+	}
+	if identity.Subject != "subject-1" || identity.Email != "jane@example.com" || !identity.EmailVerified || identity.Name != "Jane" {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+}
+
+func TestOIDCCodeExchangeRejectsWrongPKCEVerifier(t *testing.T) {
+	m := newMockOIDCProvider(t)
+	_, _, code := startLogin(t, m)
+
+	otherVerifier, _, err := GeneratePKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ExchangeOIDCCode(m.provider(), code, otherVerifier); err == nil {
+		t.Fatal("code was redeemed with a verifier that does not match the challenge")
+	}
+}
+
+func TestVerifyOIDCIDTokenRejectsNonceMismatch(t *testing.T) {
+	m := newMockOIDCProvider(t)
+	verifier, _, code := startLogin(t, m)
+
+	idToken, err := ExchangeOIDCCode(m.provider(), code, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyOIDCIDToken(m.provider(), idToken, "another-nonce"); err == nil {
+		t.Fatal("ID token was accepted with a nonce from another login")
+	}
+}
+
+func TestVerifyOIDCIDTokenRejectsBadSignature(t *testing.T) {
+	m := newMockOIDCProvider(t)
+	forger, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.signingKey = forger
+	verifier, nonce, code := startLogin(t, m)
+
+	idToken, err := ExchangeOIDCCode(m.provider(), code, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyOIDCIDToken(m.provider(), idToken, nonce); err == nil {
+		t.Fatal("ID token signed with a key missing from the JWKS was accepted")
+	}
+}
+
+func TestVerifyOIDCStateTokenRejectsForgedState(t *testing.T) {
+	config.JWTSecretKey = []byte("state-secret")
+	stateToken, err := GenerateOIDCStateToken(OIDCState{Provider: "mock", State: "state-1", Nonce: "nonce-1", CodeVerifier: "verifier"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := VerifyOIDCStateToken(stateToken)
+	if err != nil || state.State != "state-1" || state.CodeVerifier != "verifier" {
+		t.Fatalf("round trip failed: %+v %v", state, err)
+	}
+
+	config.JWTSecretKey = []byte("another-secret")
+	if _, err := VerifyOIDCStateToken(stateToken); err == nil {
+		t.Fatal("state signed with another key was accepted")
+	}
+}
+
+func TestDiscoverOIDCCacheExpires(t *testing.T) {
+	m := newMockOIDCProvider(t)
+	issuer := m.server.URL
+
+	for i := 0; i < 2; i++ {
+		if _, err := discoverOIDC(issuer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls := m.discoveryCount(); calls != 1 {
+		t.Fatalf("discovery fetched %d times, want 1 while cached", calls)
+	}
+
+	cached, _ := discoveryCache.Load(issuer)
+	cached.(*cachedDiscovery).fetchedAt = time.Now().Add(-oidcDiscoveryTTL)
+	if _, err := discoverOIDC(issuer); err != nil {
+		t.Fatal(err)
+	}
+	if calls := m.discoveryCount(); calls != 2 {
+		t.Fatalf("discovery fetched %d times, want a refetch after the TTL", calls)
+	}
+}
@@ -1,15 +1,187 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 
-// HashPassword hashes a password using bcrypt
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	"github.com/wanloq/taskinator/internal/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher hashes and verifies passwords for one algorithm.
+// Encoded hashes carry an algorithm prefix ("$2a$", "$argon2id$") and their own parameters.
+type PasswordHasher interface {
+	// Hash encodes a new hash of the password
+	Hash(password string) (string, error)
+	// Verify reports whether the password matches the encoded hash
+	Verify(encoded, password string) bool
+	// Handles reports whether the encoded hash was produced by this algorithm
+	Handles(encoded string) bool
+	// NeedsRehash reports whether an encoded hash of this algorithm uses outdated parameters
+	NeedsRehash(encoded string) bool
+}
+
+// BcryptHasher hashes passwords with bcrypt
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
 	return string(bytes), err
 }
 
-// ComparePasswords compares a hashed password with a plain password
+func (h BcryptHasher) Verify(encoded, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)) == nil
+}
+
+func (h BcryptHasher) Handles(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (h BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
+
+// Argon2idHasher hashes passwords with Argon2id, encoded in the PHC string format:
+// $argon2id$v=19$m=<memory KiB>,t=<iterations>,p=<threads>$<salt>$<key>
+type Argon2idHasher struct {
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+}
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.Memory, h.Time, h.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h Argon2idHasher) Verify(encoded, password string) bool {
+	params, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false
+	}
+	key := argon2.IDKey([]byte(password), params.salt, params.time, params.memory, params.threads, uint32(len(params.key)))
+	return subtle.ConstantTimeCompare(key, params.key) == 1
+}
+
+func (h Argon2idHasher) Handles(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (h Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, err := decodeArgon2id(encoded)
+	return err != nil || params.memory != h.Memory || params.time != h.Time || params.threads != h.Threads ||
+		len(params.key) != argon2KeyLength
+}
+
+// decodeArgon2id parses a PHC-formatted Argon2id hash
+func decodeArgon2id(encoded string) (*argon2Params, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, errors.New("unsupported argon2id version")
+	}
+
+	params := &argon2Params{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return nil, errors.New("invalid argon2id parameters")
+	}
+
+	var err error
+	if params.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, errors.New("invalid argon2id salt")
+	}
+	if params.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(params.key) == 0 {
+		return nil, errors.New("invalid argon2id key")
+	}
+	return params, nil
+}
+
+// CurrentPasswordHasher returns the hasher configured for new password hashes
+func CurrentPasswordHasher() PasswordHasher {
+	if config.Hashing.Algorithm == config.HasherBcrypt {
+		return BcryptHasher{Cost: config.Hashing.BcryptCost}
+	}
+	return Argon2idHasher{
+		Memory:  config.Hashing.Argon2Memory,
+		Time:    config.Hashing.Argon2Time,
+		Threads: config.Hashing.Argon2Threads,
+	}
+}
+
+// hasherFor returns the hasher able to verify an encoded hash, preferring the current configuration
+func hasherFor(encoded string) PasswordHasher {
+	hashers := []PasswordHasher{
+		CurrentPasswordHasher(),
+		BcryptHasher{Cost: config.Hashing.BcryptCost},
+		Argon2idHasher{Memory: config.Hashing.Argon2Memory, Time: config.Hashing.Argon2Time, Threads: config.Hashing.Argon2Threads},
+	}
+	for _, hasher := range hashers {
+		if hasher.Handles(encoded) {
+			return hasher
+		}
+	}
+	return nil
+}
+
+var (
+	dummyHash     string
+	dummyHashOnce sync.Once
+)
+
+// HashPassword hashes a password with the configured algorithm
+func HashPassword(password string) (string, error) {
+	return CurrentPasswordHasher().Hash(password)
+}
+
+// ComparePasswords compares a hashed password with a plain password, whatever algorithm produced the hash
 func ComparePasswords(hashedPassword, password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
-	return err == nil
+	hasher := hasherFor(hashedPassword)
+	return hasher != nil && hasher.Verify(hashedPassword, password)
+}
+
+// PasswordNeedsRehash reports whether a stored hash should be replaced with one using the current
+// algorithm and parameters. Call it after a successful ComparePasswords, while the plain password is known.
+func PasswordNeedsRehash(hashedPassword string) bool {
+	current := CurrentPasswordHasher()
+	return !current.Handles(hashedPassword) || current.NeedsRehash(hashedPassword)
+}
+
+// SimulatePasswordCheck spends the same time as ComparePasswords so requests for
+// unknown accounts cannot be told apart from real ones by response time
+func SimulatePasswordCheck(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = HashPassword("taskinator-dummy-password")
+	})
+	_ = ComparePasswords(dummyHash, password)
 }
@@ -0,0 +1,201 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/wanloq/taskinator/internal/config"
+)
+
+//go:embed data/breached_passwords.txt
+var bundledBreachedPasswords string
+
+// breachedPrefixLength is the number of SHA-1 hex characters that select a k-anonymity range
+const breachedPrefixLength = 5
+
+var (
+	breachedRanges     map[string]map[string]bool
+	breachedRangesOnce sync.Once
+)
+
+// ValidatePassword checks a new password against the configured policy.
+// username and email belong to the account and may be empty when unknown.
+func ValidatePassword(password, username, email string) error {
+	policy := config.Password
+	var problems []string
+
+	if utf8.RuneCountInString(password) < policy.MinLength {
+		problems = append(problems, "must be at least "+strconv.Itoa(policy.MinLength)+" characters long")
+	}
+	if len(password) > policy.MaxBytes {
+		problems = append(problems, "must be at most "+strconv.Itoa(policy.MaxBytes)+" bytes long")
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+	if policy.RequireUpper && !hasUpper {
+		problems = append(problems, "must contain an uppercase letter")
+	}
+	if policy.RequireLower && !hasLower {
+		problems = append(problems, "must contain a lowercase letter")
+	}
+	if policy.RequireDigit && !hasDigit {
+		problems = append(problems, "must contain a digit")
+	}
+	if policy.RequireSymbol && !hasSymbol {
+		problems = append(problems, "must contain a symbol")
+	}
+
+	if policy.ForbidIdentity && containsIdentity(password, username, email) {
+		problems = append(problems, "must not contain your username or email")
+	}
+
+	if policy.CheckBreached && IsBreachedPassword(password) {
+		problems = append(problems, "appears in a list of breached passwords")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("password " + strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// IsBreachedPassword reports whether the password appears in the breached-password list.
+// Lookups use the k-anonymity range model: the SHA-1 prefix selects a range, the suffix is matched within it.
+func IsBreachedPassword(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	return breachedRange(hash[:breachedPrefixLength])[hash[breachedPrefixLength:]]
+}
+
+// breachedRange returns the set of hash suffixes known for a 5-character SHA-1 prefix
+func breachedRange(prefix string) map[string]bool {
+	breachedRangesOnce.Do(func() {
+		breachedRanges = make(map[string]map[string]bool)
+		loaded := loadBreachedRanges(strings.NewReader(bundledBreachedPasswords), "")
+		if path := config.Password.BreachedList; path != "" {
+			loaded += loadBreachedList(path)
+		}
+		log.Println("Loaded", loaded, "breached password hashes")
+	})
+	return breachedRanges[prefix]
+}
+
+// loadBreachedList loads a breached-password file of full "HASH:COUNT" lines, or a directory of
+// Have I Been Pwned range files named after their prefix ("21BD1.txt") holding "SUFFIX:COUNT" lines
+func loadBreachedList(path string) int {
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Println("Could not open breached password list:", err)
+		return 0
+	}
+	if !info.IsDir() {
+		return loadBreachedFile(path, "")
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		log.Println("Could not read breached password directory:", err)
+		return 0
+	}
+	loaded := 0
+	for _, entry := range entries {
+		prefix := strings.ToUpper(strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())))
+		if entry.IsDir() || !isSHA1Hex(prefix, breachedPrefixLength) {
+			continue
+		}
+		loaded += loadBreachedFile(filepath.Join(path, entry.Name()), prefix)
+	}
+	return loaded
+}
+
+// loadBreachedFile loads one breached-password file; see loadBreachedRanges for prefix
+func loadBreachedFile(path, prefix string) int {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Println("Could not open breached password list:", err)
+		return 0
+	}
+	defer file.Close()
+	return loadBreachedRanges(file, prefix)
+}
+
+// loadBreachedRanges parses "HASH:COUNT" lines (count optional) into breachedRanges and returns how
+// many it loaded. With an empty prefix each line holds a full 40-character SHA-1; otherwise the
+// reader is the range for prefix and each line holds the 35-character suffix.
+func loadBreachedRanges(r io.Reader, prefix string) int {
+	loaded := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hash := prefix + strings.ToUpper(strings.SplitN(line, ":", 2)[0])
+		if !isSHA1Hex(hash, sha1.Size*2) {
+			continue
+		}
+		rangePrefix, suffix := hash[:breachedPrefixLength], hash[breachedPrefixLength:]
+		if breachedRanges[rangePrefix] == nil {
+			breachedRanges[rangePrefix] = make(map[string]bool)
+		}
+		breachedRanges[rangePrefix][suffix] = true
+		loaded++
+	}
+	if err := scanner.Err(); err != nil {
+		log.Println("Could not read breached password list:", err)
+	}
+	return loaded
+}
+
+// isSHA1Hex reports whether s is n upper-case hexadecimal characters
+func isSHA1Hex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'A' || r > 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// containsIdentity reports whether the password contains the username, the email or its local part
+func containsIdentity(password, username, email string) bool {
+	lowered := strings.ToLower(password)
+	candidates := []string{strings.ToLower(username), strings.ToLower(email)}
+	if local, _, found := strings.Cut(strings.ToLower(email), "@"); found {
+		candidates = append(candidates, local)
+	}
+	for _, candidate := range candidates {
+		// Very short names would reject too many legitimate passwords
+		if len(candidate) >= 3 && strings.Contains(lowered, candidate) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wanloq/taskinator/internal/config"
+)
+
+// useBreachedRanges replaces the loaded breached-password ranges for the rest of the test
+func useBreachedRanges(t *testing.T, ranges map[string]map[string]bool) {
+	t.Helper()
+	// Load the real list first so it cannot overwrite the replacement later
+	breachedRange("")
+	saved := breachedRanges
+	breachedRanges = ranges
+	t.Cleanup(func() { breachedRanges = saved })
+}
+
+// usePasswordPolicy applies policy for the rest of the test
+func usePasswordPolicy(t *testing.T, policy config.PasswordPolicy) {
+	t.Helper()
+	saved := config.Password
+	config.Password = policy
+	t.Cleanup(func() { config.Password = saved })
+}
+
+func TestValidatePassword(t *testing.T) {
+	sum := sha1.Sum([]byte("Breached-Password-1"))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	useBreachedRanges(t, map[string]map[string]bool{
+		hash[:breachedPrefixLength]: {hash[breachedPrefixLength:]: true},
+	})
+	strict := config.PasswordPolicy{
+		MinLength:      10,
+		MaxBytes:       72,
+		RequireUpper:   true,
+		RequireLower:   true,
+		RequireDigit:   true,
+		RequireSymbol:  true,
+		CheckBreached:  true,
+		ForbidIdentity: true,
+	}
+
+	cases := []struct {
+		name     string
+		policy   config.PasswordPolicy
+		password string
+		username string
+		email    string
+		// wantErr lists the problems the error must mention; none means the password is accepted
+		wantErr []string
+	}{
+		{"accepted", strict, "Tr0ub4dor&3x", "jane", "jane@example.com", nil},
+		{"too short", strict, "Ab1!", "", "", []string{"at least 10 characters"}},
+		{"length counts characters, not bytes", config.PasswordPolicy{MinLength: 4, MaxBytes: 72}, "ñññ", "", "", []string{"at least 4 characters"}},
+		{"too long", strict, "Aa1!" + strings.Repeat("x", 69), "", "", []string{"at most 72 bytes"}},
+		{"missing uppercase", strict, "tr0ub4dor&3x", "", "", []string{"uppercase letter"}},
+		{"missing lowercase", strict, "TR0UB4DOR&3X", "", "", []string{"lowercase letter"}},
+		{"missing digit", strict, "Troubador&xx", "", "", []string{"digit"}},
+		{"missing symbol", strict, "Tr0ub4dor33x", "", "", []string{"symbol"}},
+		{"character classes off", config.PasswordPolicy{MinLength: 8, MaxBytes: 72}, "aaaaaaaa", "", "", nil},
+		{"contains username", strict, "Jane-Tr0ub4dor&3x", "jane", "", []string{"username or email"}},
+		{"contains email local part", strict, "Tr0ub4dor&3x.JDoe", "", "jdoe@example.com", []string{"username or email"}},
+		{"contains email", strict, "jdoe@example.com1A", "", "jdoe@example.com", []string{"username or email"}},
+		{"short username ignored", strict, "Tr0ub4dor&3xJo", "jo", "", nil},
+		{"identity check off", config.PasswordPolicy{MinLength: 8, MaxBytes: 72}, "jane-password", "jane", "", nil},
+		{"breached", strict, "Breached-Password-1", "", "", []string{"breached passwords"}},
+		{"breached check off", config.PasswordPolicy{MinLength: 8, MaxBytes: 72}, "Breached-Password-1", "", "", nil},
+		{"every problem reported", strict, "jane", "jane", "", []string{"at least 10 characters", "uppercase letter", "digit", "symbol", "username or email"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			usePasswordPolicy(t, tc.policy)
+			err := ValidatePassword(tc.password, tc.username, tc.email)
+			if len(tc.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("rejected: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("accepted, want an error mentioning %q", tc.wantErr)
+			}
+			for _, want := range tc.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadBreachedListReadsRangeDirectory(t *testing.T) {
+	sum := sha1.Sum([]byte("correct horse battery staple 42"))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	dir := t.TempDir()
+	rangeFile := hash[:breachedPrefixLength] + ".txt"
+	if err := os.WriteFile(filepath.Join(dir, rangeFile), []byte(hash[breachedPrefixLength:]+":12\r\n"+strings.Repeat("0", 35)+":1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte(hash+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	useBreachedRanges(t, make(map[string]map[string]bool))
+	if loaded := loadBreachedList(dir); loaded != 2 {
+		t.Fatalf("loaded %d hashes, want 2", loaded)
+	}
+	if !breachedRanges[hash[:breachedPrefixLength]][hash[breachedPrefixLength:]] {
+		t.Fatal("suffix from the range file was not loaded under its prefix")
+	}
+}
+
+func TestLoadBreachedRangesSkipsMalformedLines(t *testing.T) {
+	useBreachedRanges(t, make(map[string]map[string]bool))
+	input := "# comment\n5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:3\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:3\nnot-a-hash\n"
+	if loaded := loadBreachedRanges(strings.NewReader(input), ""); loaded != 1 {
+		t.Fatalf("loaded %d hashes, want only the full hash", loaded)
+	}
+	if !breachedRanges["5BAA6"]["1E4C9B93F3F0682250B6CF8331B7EE68FD8"] {
+		t.Fatal("full hash was not loaded")
+	}
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wanloq/taskinator/internal/dto"
+)
+
+// principalKey is the fiber.Locals key under which the authenticated caller is stored
+const principalKey = "principal"
+
+// SetPrincipal stores the authenticated caller in the request context
+func SetPrincipal(c *fiber.Ctx, principal *dto.Principal) {
+	c.Locals(principalKey, principal)
+}
+
+// GetPrincipal returns the authenticated caller stored by JWTMiddleware
+func GetPrincipal(c *fiber.Ctx) (*dto.Principal, error) {
+	principal, ok := c.Locals(principalKey).(*dto.Principal)
+	if !ok || principal == nil {
+		return nil, errors.New("unauthenticated request")
+	}
+	return principal, nil
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// PersonalAccessTokenPrefix marks personal access tokens so they are never mistaken for JWTs
+const PersonalAccessTokenPrefix = "tkn_pat_"
+
+// GeneratePersonalAccessToken returns a new random personal access token and the hash to store
+func GeneratePersonalAccessToken() (token string, tokenHash string, err error) {
+	secret, err := GenerateRandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	token = PersonalAccessTokenPrefix + secret
+	return token, HashToken(token), nil
+}
+
+// GenerateOneTimeToken returns a random token to email to a user and the hash to store
+func GenerateOneTimeToken() (token string, tokenHash string, err error) {
+	token, err = GenerateRandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	return token, HashToken(token), nil
+}
+
+// GenerateRandomToken returns n cryptographically random bytes encoded as URL-safe base64
+func GenerateRandomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// IsPersonalAccessToken reports whether a bearer token is a personal access token
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// HashToken returns the hex SHA-256 digest used to store and look up random tokens
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/models"
+)
+
+var (
+	relyingParty     *webauthn.WebAuthn
+	relyingPartyErr  error
+	relyingPartyOnce sync.Once
+)
+
+// WebAuthn returns the relying party built from the loaded configuration
+func WebAuthn() (*webauthn.WebAuthn, error) {
+	relyingPartyOnce.Do(func() {
+		relyingParty, relyingPartyErr = webauthn.New(&webauthn.Config{
+			RPID:          config.WebAuthn.RPID,
+			RPDisplayName: config.WebAuthn.RPDisplayName,
+			RPOrigins:     config.WebAuthn.RPOrigins,
+		})
+	})
+	return relyingParty, relyingPartyErr
+}
+
+// WebAuthnUser adapts a user and their stored passkeys to the webauthn.User interface
+type WebAuthnUser struct {
+	User        *models.User
+	Credentials []models.WebAuthnCredential
+}
+
+// WebAuthnUserHandle encodes a user ID as the opaque WebAuthn user handle
+func WebAuthnUserHandle(userID uint) []byte {
+	handle := make([]byte, 8)
+	binary.BigEndian.PutUint64(handle, uint64(userID))
+	return handle
+}
+
+// UserIDFromWebAuthnHandle decodes a user handle produced by WebAuthnUserHandle
+func UserIDFromWebAuthnHandle(handle []byte) (uint, error) {
+	if len(handle) != 8 {
+		return 0, errors.New("invalid user handle")
+	}
+	return uint(binary.BigEndian.Uint64(handle)), nil
+}
+
+func (u *WebAuthnUser) WebAuthnID() []byte {
+	return WebAuthnUserHandle(u.User.ID)
+}
+
+func (u *WebAuthnUser) WebAuthnName() string {
+	return u.User.Email
+}
+
+func (u *WebAuthnUser) WebAuthnDisplayName() string {
+	return u.User.Username
+}
+
+func (u *WebAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.Credentials))
+	for _, stored := range u.Credentials {
+		credentials = append(credentials, CredentialFromModel(stored))
+	}
+	return credentials
+}
+
+// CredentialFromModel converts a stored passkey into the library representation
+func CredentialFromModel(stored models.WebAuthnCredential) webauthn.Credential {
+	var transports []protocol.AuthenticatorTransport
+	for _, transport := range strings.Split(stored.Transports, ",") {
+		if transport != "" {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+	}
+	return webauthn.Credential{
+		ID:              stored.CredentialID,
+		PublicKey:       stored.PublicKey,
+		AttestationType: stored.AttestationType,
+		Transport:       transports,
+		Flags:           webauthn.NewCredentialFlags(protocol.AuthenticatorFlags(stored.Flags)),
+		Authenticator: webauthn.Authenticator{
+			AAGUID:       stored.AAGUID,
+			SignCount:    stored.SignCount,
+			CloneWarning: stored.CloneWarning,
+		},
+	}
+}
+
+// CredentialToModel converts a newly registered library credential into a storable passkey
+func CredentialToModel(userID uint, name string, credential *webauthn.Credential) models.WebAuthnCredential {
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+	return models.WebAuthnCredential{
+		UserID:          userID,
+		Name:            name,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      strings.Join(transports, ","),
+		Flags:           uint8(credential.Flags.ProtocolValue()),
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+	}
+}
@@ -0,0 +1,270 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/models"
+	"gorm.io/gorm"
+)
+
+// Authenticator data flags set by the software authenticator
+const (
+	flagUserPresent      = 0x01
+	flagUserVerified     = 0x04
+	flagAttestedCredData = 0x40
+)
+
+// softAuthenticator is a platform authenticator in software: it holds one ES256 passkey and
+// produces the same attestation and assertion responses a browser would post
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatal(err)
+	}
+	return &softAuthenticator{key: key, credentialID: credentialID}
+}
+
+// authenticatorData builds rpIdHash || flags || signCount, followed by any attested credential data
+func (a *softAuthenticator) authenticatorData(flags byte, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(config.WebAuthn.RPID))
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return append(data, attested...)
+}
+
+func (a *softAuthenticator) clientData(t *testing.T, ceremony string, challenge protocol.URLEncodedBase64) []byte {
+	t.Helper()
+	clientData, err := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": challenge.String(),
+		"origin":    config.WebAuthn.RPOrigins[0],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clientData
+}
+
+// register answers a registration ceremony with a "none" attestation, keeping the user handle as a
+// resident key would
+func (a *softAuthenticator) register(t *testing.T, options *protocol.CredentialCreation) []byte {
+	t.Helper()
+	userHandle, ok := options.Response.User.ID.(protocol.URLEncodedBase64)
+	if !ok {
+		t.Fatalf("unexpected user handle type %T", options.Response.User.ID)
+	}
+	a.userHandle = userHandle
+
+	publicKey, err := webauthncbor.Marshal(map[int]interface{}{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: a.key.X.FillBytes(make([]byte, 32)),
+		-3: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attested := make([]byte, 16) // zero AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credentialID)))
+	attested = append(attested, a.credentialID...)
+	attested = append(attested, publicKey...)
+
+	attestationObject, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": a.authenticatorData(flagUserPresent|flagUserVerified|flagAttestedCredData, attested),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.response(t, map[string]string{
+		"clientDataJSON":    encode(a.clientData(t, "webauthn.create", options.Response.Challenge)),
+		"attestationObject": encode(attestationObject),
+	})
+}
+
+// assert answers a login ceremony, advancing the signature counter like a real authenticator
+func (a *softAuthenticator) assert(t *testing.T, options *protocol.CredentialAssertion) []byte {
+	t.Helper()
+	a.signCount++
+	authData := a.authenticatorData(flagUserPresent|flagUserVerified, nil)
+	clientData := a.clientData(t, "webauthn.get", options.Response.Challenge)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.response(t, map[string]string{
+		"clientDataJSON":    encode(clientData),
+		"authenticatorData": encode(authData),
+		"signature":         encode(signature),
+		"userHandle":        encode(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) response(t *testing.T, response map[string]string) []byte {
+	t.Helper()
+	body, err := json.Marshal(map[string]interface{}{
+		"id":       encode(a.credentialID),
+		"rawId":    encode(a.credentialID),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// storedSession round-trips ceremony state through JSON, as it is kept in webauthn_sessions
+func storedSession(t *testing.T, session *webauthn.SessionData) webauthn.SessionData {
+	t.Helper()
+	data, err := json.Marshal(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored webauthn.SessionData
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatal(err)
+	}
+	return restored
+}
+
+// registerPasskey runs a registration ceremony and returns the passkey as it would be stored
+func registerPasskey(t *testing.T, rp *webauthn.WebAuthn, user *WebAuthnUser, authenticator *softAuthenticator) models.WebAuthnCredential {
+	t.Helper()
+	options, session, err := rp.BeginRegistration(user, webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(authenticator.register(t, options)))
+	if err != nil {
+		t.Fatalf("parse registration: %v", err)
+	}
+	credential, err := rp.CreateCredential(user, storedSession(t, session), parsed)
+	if err != nil {
+		t.Fatalf("CreateCredential: %v", err)
+	}
+	return CredentialToModel(user.User.ID, "Laptop", credential)
+}
+
+// loginWithPasskey runs a discoverable login ceremony against the user's stored passkeys
+func loginWithPasskey(t *testing.T, rp *webauthn.WebAuthn, user *WebAuthnUser, authenticator *softAuthenticator) *webauthn.Credential {
+	t.Helper()
+	options, session, err := rp.BeginDiscoverableLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(authenticator.assert(t, options)))
+	if err != nil {
+		t.Fatalf("parse assertion: %v", err)
+	}
+	credential, err := rp.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		userID, err := UserIDFromWebAuthnHandle(userHandle)
+		if err != nil {
+			return nil, err
+		}
+		if userID != user.User.ID {
+			t.Fatalf("user handle resolved to user %d, want %d", userID, user.User.ID)
+		}
+		return user, nil
+	}, storedSession(t, session), parsed)
+	if err != nil {
+		t.Fatalf("ValidateDiscoverableLogin: %v", err)
+	}
+	return credential
+}
+
+func newPasskeyUser(t *testing.T) (*webauthn.WebAuthn, *WebAuthnUser) {
+	t.Helper()
+	rp, err := WebAuthn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rp, &WebAuthnUser{User: &models.User{Model: gorm.Model{ID: 42}, Email: "jane@example.com", Username: "jane"}}
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	rp, user := newPasskeyUser(t)
+	authenticator := newSoftAuthenticator(t)
+
+	stored := registerPasskey(t, rp, user, authenticator)
+	if !bytes.Equal(stored.CredentialID, authenticator.credentialID) || stored.UserID != user.User.ID || len(stored.PublicKey) == 0 {
+		t.Fatalf("unexpected stored passkey %+v", stored)
+	}
+
+	user.Credentials = []models.WebAuthnCredential{stored}
+	credential := loginWithPasskey(t, rp, user, authenticator)
+	if credential.Authenticator.CloneWarning || credential.Authenticator.SignCount != authenticator.signCount {
+		t.Fatalf("sign count %d clone warning %v, want %d and no warning",
+			credential.Authenticator.SignCount, credential.Authenticator.CloneWarning, authenticator.signCount)
+	}
+}
+
+func TestPasskeyLoginFlagsSignCountRegression(t *testing.T) {
+	rp, user := newPasskeyUser(t)
+	authenticator := newSoftAuthenticator(t)
+	stored := registerPasskey(t, rp, user, authenticator)
+
+	// Record a few logins the way FinishPasskeyLogin does
+	for i := 0; i < 3; i++ {
+		user.Credentials = []models.WebAuthnCredential{stored}
+		credential := loginWithPasskey(t, rp, user, authenticator)
+		if credential.Authenticator.CloneWarning {
+			t.Fatalf("login %d flagged as cloned", i+1)
+		}
+		stored.SignCount = credential.Authenticator.SignCount
+		stored.Flags = uint8(credential.Flags.ProtocolValue())
+	}
+
+	// A copy of the key that fell behind the original presents an older counter
+	authenticator.signCount = 1
+	user.Credentials = []models.WebAuthnCredential{stored}
+	credential := loginWithPasskey(t, rp, user, authenticator)
+	if !credential.Authenticator.CloneWarning {
+		t.Fatalf("counter %d after stored %d was not flagged", credential.Authenticator.SignCount, stored.SignCount)
+	}
+}
+
+func TestWebAuthnUserHandleRoundTrip(t *testing.T) {
+	userID, err := UserIDFromWebAuthnHandle(WebAuthnUserHandle(123456))
+	if err != nil || userID != 123456 {
+		t.Fatalf("got %d, %v", userID, err)
+	}
+	if _, err := UserIDFromWebAuthnHandle([]byte("short")); err == nil {
+		t.Fatal("malformed handle was accepted")
+	}
+}
@@ -4,27 +4,18 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 
 	_ "github.com/wanloq/taskinator/docs"
 	"github.com/wanloq/taskinator/internal/config"
+	"github.com/wanloq/taskinator/internal/jobs"
 	"github.com/wanloq/taskinator/internal/routes"
+	"github.com/wanloq/taskinator/internal/utils"
 )
 
-type Task struct {
-	ID     int    `json:"id"`
-	Name   string `json:"name"`
-	Status bool   `json:"status"`
-}
-
-var tasks = []Task{
-	{ID: 1, Name: "Learn Go", Status: false},
-	{ID: 2, Name: "Learn Fiber", Status: false},
-	{ID: 3, Name: "Integrate Swagger", Status: true},
-}
-
 // @title Taskinator API
 // @version 1.0
 // @description A simple Task Manager API using Fiber and Swagger implemented in Go
@@ -41,6 +32,9 @@ func main() {
 		log.Fatalf("Error loading config: %v", err)
 	}
 
+	// Set up outgoing email; a missing configuration disables it instead of failing
+	utils.InitMailer()
+
 	// Connect to database
 	db, err := config.ConnectDB()
 	if err != nil {
@@ -53,6 +47,12 @@ func main() {
 		log.Fatalf("Migration failed: %v", err)
 	}
 
+	// Background jobs
+	jobs.StartAccountPurge(config.Account.PurgeInterval)
+	jobs.StartEmailWorkers(config.Mail.Workers, 5*time.Second)
+	jobs.StartDigestJob(config.Mail.DigestInterval)
+	jobs.StartWebAuthnSessionCleanup(15 * time.Minute)
+
 	// Server code
 	app := fiber.New()
 	app.Use(logger.New())
@@ -60,6 +60,10 @@ func main() {
 	// Routes
 	routes.SetupRoutes(app)
 	routes.SetupUserRoutes(app)
+	routes.SetupAdminRoutes(app)
+	routes.SetupShareRoutes(app)
+	routes.SetupNotificationRoutes(app)
+	routes.SetupTaskRoutes(app)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -71,67 +75,3 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
-
-// View all tasks
-func GetAll(c *fiber.Ctx) error {
-	if len(tasks) == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "No tasks found"})
-	}
-	return c.JSON(tasks)
-}
-
-// Create a new task
-func CreateTask(c *fiber.Ctx) error {
-	task := new(Task)
-	err := c.BodyParser(task)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
-	}
-	id := len(tasks) + 1
-	for _, task := range tasks {
-		if task.ID >= id {
-			id = task.ID + 1
-		}
-	}
-	task.ID = id
-	tasks = append(tasks, *task)
-	return c.Status(fiber.StatusCreated).JSON(task)
-}
-
-// Mark a task as done
-func FinishTask(c *fiber.Ctx) error {
-	id, err := c.ParamsInt("id")
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
-	}
-	if len(tasks) == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "No tasks found"})
-	}
-
-	for i, task := range tasks {
-		if task.ID == id {
-			tasks[i].Status = true
-			return c.JSON(tasks[i])
-		}
-	}
-	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Task not found"})
-}
-
-// Delete a task
-func DeleteTask(c *fiber.Ctx) error {
-	id, err := c.ParamsInt("id")
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
-	}
-	if len(tasks) == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "No tasks found"})
-	}
-
-	for i, task := range tasks {
-		if task.ID == id {
-			tasks[i].Status = true
-			return c.JSON(tasks[i])
-		}
-	}
-	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Task not found"})
-}